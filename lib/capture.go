@@ -0,0 +1,88 @@
+package lib
+
+import (
+	"image"
+	"time"
+
+	"github.com/kbinani/screenshot"
+)
+
+// resetFrameCount is called when a recording starts.
+func (g *App) resetFrameCount() {
+	g.frameCount = 0
+	g.recordStart = time.Now()
+}
+
+// frameArea is where a frame is captured from, in physical
+// pixels relative to the main display.
+type frameArea struct {
+	// the captured area, see captureArea
+	Bounds image.Rectangle
+
+	// the position of the display the window is on
+	Origin image.Point
+
+	// the cage, which the redactions are relative to
+	Cage image.Rectangle
+}
+
+// redactionOffset returns where the cage is in the frame.
+func (area frameArea) redactionOffset() image.Point {
+	return area.Cage.Min.Sub(area.Bounds.Min)
+}
+
+// captureFrame takes a screenshot of the capture area, and applies
+// the frame settings to it before it's passed on to be saved.
+func (g *App) captureFrame() (*image.RGBA, error) {
+	img, area, err := g.captureRaw()
+	if err != nil {
+		return nil, err
+	}
+	return g.processFrame(img, area), nil
+}
+
+// captureRaw takes a screenshot of the capture area without
+// any overlays.
+func (g *App) captureRaw() (*image.RGBA, frameArea, error) {
+	var area frameArea
+	var err error
+	area.Bounds, area.Origin, err = g.captureArea()
+	if err != nil {
+		return nil, area, err
+	}
+	area.Cage = GetWindowBounds().Add(area.Origin)
+	area = g.trackArea(area)
+
+	var img *image.RGBA
+	if g.settings.Display.Straddle == StraddleStitch {
+		img, err = captureStitched(area.Bounds, g.displays)
+	} else {
+		img, err = screenshot.CaptureRect(area.Bounds)
+	}
+	if err != nil {
+		return nil, area, err
+	}
+	img = normalizeFrame(img, g.recordArea.Bounds.Size())
+	g.frameCount++
+	return img, area, nil
+}
+
+// processFrame draws the overlays on a frame captured from area.
+// Redactions always come first, so that nothing sensitive is left in
+// the frame. The cursor is drawn before scaling, text after.
+func (g *App) processFrame(img *image.RGBA, area frameArea) *image.RGBA {
+	s := &g.settings
+	ApplyRedactions(img, s.Redactions, area.redactionOffset())
+	DrawCursor(img, GetCursorState(area.Bounds.Sub(area.Origin)), s.Cursor)
+	img = ScaleImage(img, s.Scale)
+	if logo := g.getLogo(); logo != nil {
+		logo.Draw(img)
+	}
+	if s.Stamp.Show {
+		g.drawStamp(img)
+	}
+	if s.Keystrokes.Show {
+		g.drawKeystrokes(img)
+	}
+	return img
+}
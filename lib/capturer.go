@@ -2,10 +2,13 @@ package lib
 
 import (
 	"image"
+	"math"
+	"time"
 
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/hajimehoshi/ebiten/v2/inpututil"
 	"github.com/nvlled/carrot"
+	"github.com/nvlled/screencage/lib/framerate"
 )
 
 const (
@@ -21,10 +24,41 @@ type Capturer interface {
 	IsRunning() bool
 }
 
+// width of the border drawn by App.drawBorder, in device-independent pixels
+const borderWidth = 2
+
+// The capture area is the window without the border, and
+// one more pixel so that the border never shows in the frames.
+const captureInset = borderWidth + 1
+
 func GetWindowBounds() image.Rectangle {
 	x, y := ebiten.WindowPosition()
 	w, h := ebiten.WindowSize()
-	return image.Rect(x+3, y+3, x+w-3, y+h-3)
+	return captureBounds(x, y, w, h, ebiten.DeviceScaleFactor())
+}
+
+// captureBounds maps the window position and size, which are in
+// device-independent pixels, to the capture area in physical pixels.
+func captureBounds(x, y, w, h int, scale float64) image.Rectangle {
+	r := image.Rect(x+captureInset, y+captureInset, x+w-captureInset, y+h-captureInset)
+	return image.Rectangle{scalePoint(r.Min, scale), scalePoint(r.Max, scale)}
+}
+
+// windowToCapture converts a position on the window
+// to a position on the captured frames.
+func windowToCapture(p image.Point, scale float64) image.Point {
+	return scalePoint(p.Sub(image.Pt(captureInset, captureInset)), scale)
+}
+
+func captureToWindow(p image.Point, scale float64) image.Point {
+	return scalePoint(p, 1/scale).Add(image.Pt(captureInset, captureInset))
+}
+
+func scalePoint(p image.Point, scale float64) image.Point {
+	return image.Pt(
+		int(math.Round(float64(p.X)*scale)),
+		int(math.Round(float64(p.Y)*scale)),
+	)
 }
 
 func awaitEnter(ctrl *carrot.Control) {
@@ -34,6 +68,17 @@ func awaitEnter(ctrl *carrot.Control) {
 	})
 }
 
+// awaitTick waits for the next frame of the schedule,
+// and returns its time on the timeline.
+func awaitTick(ctrl *carrot.Control, sched *framerate.Scheduler) time.Duration {
+	if d := sched.Wait(); d > 0 {
+		ctrl.Sleep(d)
+	} else {
+		ctrl.Yield()
+	}
+	return sched.Tick()
+}
+
 func awaitNextDraw(ctrl *carrot.Control, lasttDraw *int64) {
 	prevLastDraw := *lasttDraw
 	for {
@@ -0,0 +1,289 @@
+package lib
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/nvlled/screencage/lib/gifedit"
+)
+
+// RunCommand runs a command-line subcommand,
+// such as `screencage repair capture.gif`,
+// instead of opening the capture window.
+func RunCommand(name string, args []string) error {
+	switch name {
+	case "repair":
+		return runRepair(args)
+	case "trim":
+		return runTrim(args)
+	case "speed":
+		return runSpeed(args)
+	}
+	return fmt.Errorf("unknown command: %v", name)
+}
+
+func runRepair(args []string) error {
+	flags := flag.NewFlagSet("repair", flag.ExitOnError)
+	output := flags.String("o", "", "output file, the gif is repaired in place if not given")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: screencage repair [-o output.gif] file.gif...")
+		fmt.Fprintln(flags.Output(), "Interrupted recordings are left as *.gif.*.tmp files next to the output file.")
+		fmt.Fprintln(flags.Output(), "On startup, they are only looked for in the last", maxRecentDirs, "output directories,")
+		fmt.Fprintln(flags.Output(), "pass them here to repair the ones anywhere else.")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	if *output != "" && flags.NArg() > 1 {
+		return errors.New("-o can only be used with a single file")
+	}
+
+	for _, filename := range flags.Args() {
+		saved, info, err := RepairGifFile(filename, *output)
+		if errors.Is(err, gifedit.ErrNotDamaged) {
+			fmt.Printf("%v: nothing to repair\n", filename)
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("%v: %w", filename, err)
+		}
+		fmt.Printf("%v: kept %v frames, dropped %v bytes, saved to %v\n",
+			filename, info.NumFrames, info.NumDropped, saved)
+	}
+
+	return nil
+}
+
+// RepairGifFile repairs a truncated gif and writes it to target,
+// returning the filename it was saved to. An empty target means the
+// file is repaired in place, or for a leftover temporary file from an
+// interrupted recording, saved next to the file it was meant to replace.
+func RepairGifFile(filename, target string) (string, gifedit.RepairInfo, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return "", gifedit.RepairInfo{}, err
+	}
+
+	pendingTarget, isPending := pendingFileTarget(filename)
+
+	repaired, info, err := gifedit.Repair(data)
+	if errors.Is(err, gifedit.ErrNotDamaged) && isPending {
+		// the recording was finished, but not renamed yet
+		err = nil
+	}
+	if err != nil {
+		return "", info, err
+	}
+
+	if target == "" {
+		target = filename
+		if isPending {
+			target = recoveredFilename(pendingTarget)
+		}
+	}
+
+	output, err := CreatePendingFile(target, false)
+	if err != nil {
+		return "", info, err
+	}
+	if _, err := output.Write(repaired); err != nil {
+		output.Discard()
+		return "", info, err
+	}
+	if err := output.Commit(); err != nil {
+		output.Discard()
+		return "", info, err
+	}
+
+	if isPending {
+		os.Remove(filename)
+	}
+
+	return target, info, nil
+}
+
+func runTrim(args []string) error {
+	var opts gifedit.TrimOptions
+
+	flags := flag.NewFlagSet("trim", flag.ExitOnError)
+	flags.DurationVar(&opts.Start, "start", 0, "drop this much from the start, e.g. 1.5s")
+	flags.DurationVar(&opts.End, "end", 0, "drop this much from the end, e.g. 2s")
+	frames := flags.String("frames", "", "keep only the frames in the range from:to, e.g. 10:50, 10: or :50")
+	output := flags.String("o", "", "output file, the gif is edited in place if not given")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: screencage trim [-start 1s] [-end 1s] [-frames from:to] [-o output.gif] file.gif")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	if *frames != "" {
+		var err error
+		opts.From, opts.To, err = parseFrameRange(*frames)
+		if err != nil {
+			return err
+		}
+	}
+
+	filename := flags.Arg(0)
+	saved, err := TrimGifFile(filename, *output, opts)
+	if err != nil {
+		return fmt.Errorf("%v: %w", filename, err)
+	}
+	fmt.Printf("%v: saved to %v\n", filename, saved)
+
+	return nil
+}
+
+// TrimGifFile drops frames from the start and end of a gif, see gifedit.Trim.
+// An empty target means the gif is edited in place.
+func TrimGifFile(filename, target string, opts gifedit.TrimOptions) (string, error) {
+	return editGifFile(filename, target, func(anim *gifedit.Animation) error {
+		return gifedit.Trim(anim, opts)
+	})
+}
+
+func runSpeed(args []string) error {
+	flags := flag.NewFlagSet("speed", flag.ExitOnError)
+	factor := flags.Float64("x", 2, "speed factor, 2 plays twice as fast")
+	decimate := flags.Int("decimate", 1, "keep only every nth frame")
+	output := flags.String("o", "", "output file, defaults to file-2x.gif")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: screencage speed [-x 2] [-decimate 2] [-o output.gif] file.gif")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	filename := flags.Arg(0)
+	target := *output
+	if target == "" {
+		base, ext := TrimExt(filename)
+		target = fmt.Sprintf("%v-%vx%v", base, strconv.FormatFloat(*factor, 'f', -1, 64), ext)
+	}
+
+	saved, err := SpeedGifFile(filename, target, *factor, *decimate)
+	if err != nil {
+		return fmt.Errorf("%v: %w", filename, err)
+	}
+	fmt.Printf("%v: saved to %v\n", filename, saved)
+
+	return nil
+}
+
+// SpeedGifFile changes the playback speed of a gif, and keeps only
+// every nth frame if decimate is greater than 1.
+// An empty target means the gif is edited in place.
+func SpeedGifFile(filename, target string, factor float64, decimate int) (string, error) {
+	return editGifFile(filename, target, func(anim *gifedit.Animation) error {
+		if err := gifedit.Decimate(anim, decimate); err != nil {
+			return err
+		}
+		return gifedit.Speed(anim, factor)
+	})
+}
+
+// editGifFile decodes a gif, applies edit to it, and then
+// writes it to target, or back to filename if target is empty.
+func editGifFile(filename, target string, edit func(*gifedit.Animation) error) (string, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return "", err
+	}
+	anim, err := gifedit.Decode(file)
+	file.Close()
+	if err != nil {
+		return "", err
+	}
+
+	if err := edit(anim); err != nil {
+		return "", err
+	}
+
+	if target == "" {
+		target = filename
+	}
+	output, err := CreatePendingFile(target, false)
+	if err != nil {
+		return "", err
+	}
+	if err := anim.Encode(output); err != nil {
+		output.Discard()
+		return "", err
+	}
+	if err := output.Commit(); err != nil {
+		output.Discard()
+		return "", err
+	}
+
+	return target, nil
+}
+
+func parseFrameRange(str string) (from, to int, err error) {
+	fromStr, toStr, ok := strings.Cut(str, ":")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid frame range %q, expected from:to", str)
+	}
+	if fromStr != "" {
+		if from, err = strconv.Atoi(fromStr); err != nil {
+			return 0, 0, fmt.Errorf("invalid frame range %q: %w", str, err)
+		}
+	}
+	if toStr != "" {
+		if to, err = strconv.Atoi(toStr); err != nil {
+			return 0, 0, fmt.Errorf("invalid frame range %q: %w", str, err)
+		}
+	}
+	return from, to, nil
+}
+
+// FindPendingGifs returns the leftover temporary files
+// of interrupted recordings in dir.
+func FindPendingGifs(dir string) []string {
+	files, err := filepath.Glob(filepath.Join(escapeGlob(dir), "*.gif.*"+pendingFileSuffix))
+	if err != nil {
+		return nil
+	}
+	return files
+}
+
+// pendingFileTarget returns the output file that
+// a file created with CreatePendingFile was meant to replace.
+func pendingFileTarget(filename string) (string, bool) {
+	if !strings.HasSuffix(filename, pendingFileSuffix) {
+		return "", false
+	}
+	target, random := TrimExt(strings.TrimSuffix(filename, pendingFileSuffix))
+	if random == "" || !isDigits(random[1:]) || filepath.Ext(target) == "" {
+		return "", false
+	}
+	return target, true
+}
+
+// recoveredFilename returns where a recovered recording for target is saved.
+// An empty target is a name reserved by the interrupted recording, so it is
+// used as is, but an existing capture is never replaced.
+func recoveredFilename(target string) string {
+	info, err := os.Stat(target)
+	if errors.Is(err, os.ErrNotExist) || (err == nil && info.Size() == 0) {
+		return target
+	}
+	return PartialFilename(target)
+}
@@ -0,0 +1,143 @@
+package lib
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+type CursorSettings struct {
+	// Draw the mouse cursor on the frames.
+	Show bool `json:"show"`
+
+	// Draw a ring around the cursor while a mouse button is pressed.
+	HighlightClicks bool `json:"highlightClicks"`
+}
+
+func (s CursorSettings) String() string {
+	switch {
+	case s.Show && s.HighlightClicks:
+		return "shown, with clicks"
+	case s.Show:
+		return "shown"
+	}
+	return "hidden"
+}
+
+// Next cycles through hidden, shown, and shown with clicks.
+func (s *CursorSettings) Next() {
+	switch {
+	case s.Show && s.HighlightClicks:
+		s.Show, s.HighlightClicks = false, false
+	case s.Show:
+		s.HighlightClicks = true
+	default:
+		s.Show = true
+	}
+}
+
+// CursorState is the mouse cursor at the time a frame is captured.
+type CursorState struct {
+	// Position relative to the capture area.
+	Position image.Point
+
+	LeftPressed  bool
+	RightPressed bool
+}
+
+// GetCursorState returns the cursor position relative to bounds,
+// which is in physical pixels.
+func GetCursorState(bounds image.Rectangle) CursorState {
+	x, y := ebiten.CursorPosition()
+	wx, wy := ebiten.WindowPosition()
+	screenPos := scalePoint(image.Pt(wx+x, wy+y), ebiten.DeviceScaleFactor())
+
+	return CursorState{
+		Position:     screenPos.Sub(bounds.Min),
+		LeftPressed:  ebiten.IsMouseButtonPressed(ebiten.MouseButtonLeft),
+		RightPressed: ebiten.IsMouseButtonPressed(ebiten.MouseButtonRight),
+	}
+}
+
+var (
+	cursorImage = createCursorImage()
+	clickRing   = createRingMask(16, 3)
+
+	ColorClickLeft  = color.RGBA{255, 220, 0, 180}
+	ColorClickRight = color.RGBA{0, 160, 255, 180}
+)
+
+// DrawCursor draws the cursor, and the click highlight
+// if enabled, on img.
+func DrawCursor(img *image.RGBA, cursor CursorState, s CursorSettings) {
+	if !s.Show {
+		return
+	}
+
+	if s.HighlightClicks && (cursor.LeftPressed || cursor.RightPressed) {
+		var ringColor color.Color = ColorClickLeft
+		if cursor.RightPressed {
+			ringColor = ColorClickRight
+		}
+		rb := clickRing.Bounds()
+		r := rb.Sub(rb.Size().Div(2)).Add(cursor.Position)
+		draw.DrawMask(img, r, image.NewUniform(ringColor), image.Point{}, clickRing, rb.Min, draw.Over)
+	}
+
+	r := cursorImage.Bounds().Add(cursor.Position)
+	draw.Draw(img, r, cursorImage, image.Point{}, draw.Over)
+}
+
+// the classic arrow pointer, X is the outline
+var cursorSprite = []string{
+	"X",
+	"XX",
+	"X.X",
+	"X..X",
+	"X...X",
+	"X....X",
+	"X.....X",
+	"X......X",
+	"X.......X",
+	"X........X",
+	"X.....XXXXX",
+	"X..X..X",
+	"X.X X..X",
+	"XX  X..X",
+	"X    X..X",
+	"     X..X",
+	"      XX",
+}
+
+func createCursorImage() *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, 11, len(cursorSprite)))
+	for y, row := range cursorSprite {
+		for x, ch := range row {
+			switch ch {
+			case 'X':
+				img.Set(x, y, ColorBlack)
+			case '.':
+				img.Set(x, y, ColorWhite)
+			}
+		}
+	}
+	return img
+}
+
+func createRingMask(radius, thickness int) *image.Alpha {
+	size := radius*2 + 1
+	mask := image.NewAlpha(image.Rect(0, 0, size, size))
+	outer := radius * radius
+	inner := (radius - thickness) * (radius - thickness)
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			dx, dy := x-radius, y-radius
+			if d := dx*dx + dy*dy; d <= outer && d >= inner {
+				mask.SetAlpha(x, y, color.Alpha{255})
+			}
+		}
+	}
+	return mask
+}
@@ -0,0 +1,176 @@
+package lib
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/kbinani/screenshot"
+)
+
+type DisplaySettings struct {
+	// 0 captures the cage, N captures the whole display N.
+	Capture int `json:"capture"`
+
+	// What to do with a cage that is on more than one display.
+	Straddle StraddleMode `json:"straddle"`
+}
+
+type StraddleMode int
+
+const (
+	// capture only the part on the display the window is on
+	StraddleClip StraddleMode = iota
+	// capture each display separately and combine the parts
+	StraddleStitch
+
+	StraddleMode_Size
+)
+
+func (mode StraddleMode) String() string {
+	switch mode {
+	case StraddleClip:
+		return "clip"
+	case StraddleStitch:
+		return "stitch"
+	}
+	return "invalid-straddle-mode"
+}
+
+func ParseStraddleMode(str string) (StraddleMode, error) {
+	for mode := StraddleMode(0); mode < StraddleMode_Size; mode++ {
+		if mode.String() == str {
+			return mode, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid straddle mode: %v, must be clip or stitch", str)
+}
+
+// GetDisplays returns the bounds of the active displays,
+// in physical pixels, relative to the main display.
+func GetDisplays() []image.Rectangle {
+	var displays []image.Rectangle
+	for i := 0; i < screenshot.NumActiveDisplays(); i++ {
+		displays = append(displays, screenshot.GetDisplayBounds(i))
+	}
+	return displays
+}
+
+// findDisplay returns the index of the display the window is on,
+// the one with the centre of the window, in physical pixels on the
+// desktop. ebiten only tells the position relative to the current
+// monitor, so where the platform can't tell, window is empty, and
+// the displays are matched by the size of the current monitor,
+// preferring the previous one.
+func findDisplay(displays []image.Rectangle, window image.Rectangle, size image.Point, prev int) int {
+	if !window.Empty() {
+		center := window.Min.Add(window.Max).Div(2)
+		for i, display := range displays {
+			if center.In(display) {
+				return i
+			}
+		}
+	}
+
+	if prev >= 0 && prev < len(displays) && displays[prev].Size() == size {
+		return prev
+	}
+	for i, display := range displays {
+		if display.Size() == size {
+			return i
+		}
+	}
+	if prev >= 0 && prev < len(displays) {
+		return prev
+	}
+	return 0
+}
+
+// stitchParts splits r into the parts on each display.
+func stitchParts(r image.Rectangle, displays []image.Rectangle) []image.Rectangle {
+	var parts []image.Rectangle
+	for _, display := range displays {
+		if part := r.Intersect(display); !part.Empty() {
+			parts = append(parts, part)
+		}
+	}
+	return parts
+}
+
+// captureStitched captures r even if it's on several displays.
+// Parts of r that are not on any display are left black.
+func captureStitched(r image.Rectangle, displays []image.Rectangle) (*image.RGBA, error) {
+	parts := stitchParts(r, displays)
+	if len(parts) == 1 && parts[0] == r {
+		return screenshot.CaptureRect(r)
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, r.Dx(), r.Dy()))
+	draw.Draw(img, img.Rect, image.NewUniform(ColorBlack), image.Point{}, draw.Src)
+	for _, part := range parts {
+		partImg, err := screenshot.CaptureRect(part)
+		if err != nil {
+			return nil, err
+		}
+		draw.Draw(img, part.Sub(r.Min), partImg, partImg.Rect.Min, draw.Src)
+	}
+	return img, nil
+}
+
+// updateDisplays refreshes the displays, and
+// finds which one the window is on.
+func (g *App) updateDisplays() {
+	g.displays = GetDisplays()
+	w, h := ebiten.ScreenSizeInFullscreen()
+	size := scalePoint(image.Pt(w, h), ebiten.DeviceScaleFactor())
+	window, _ := desktopWindowBounds()
+	g.display = findDisplay(g.displays, window, size, g.display)
+}
+
+// captureArea returns the area to capture, in physical pixels
+// relative to the main display, and the origin of the display
+// the window is on.
+func (g *App) captureArea() (image.Rectangle, image.Point, error) {
+	var current image.Rectangle
+	if g.display < len(g.displays) {
+		current = g.displays[g.display]
+	}
+
+	if g.follow != nil {
+		bounds, err := g.follow.conn.Bounds(g.follow.Window)
+		if err != nil {
+			g.stopFollowing()
+			g.followErr = err
+		}
+		return bounds, current.Min, err
+	}
+
+	s := g.settings.Display
+	if s.Capture > 0 && s.Capture <= len(g.displays) {
+		return g.displays[s.Capture-1], current.Min, nil
+	}
+
+	bounds := GetWindowBounds().Add(current.Min)
+	if s.Straddle == StraddleClip && !current.Empty() {
+		bounds = bounds.Intersect(current)
+	}
+	return bounds, current.Min, nil
+}
+
+// displayInfo describes what is captured for the overlay.
+func (g *App) displayInfo() string {
+	n := len(g.displays)
+	if capture := g.settings.Display.Capture; capture > 0 {
+		return fmt.Sprintf("whole display %v of %v", capture, n)
+	}
+	return fmt.Sprintf("cage on display %v of %v", g.display+1, n)
+}
+
+// nextDisplay cycles through capturing the cage,
+// and capturing each whole display.
+func (g *App) nextDisplay() {
+	s := &g.settings.Display
+	s.Capture = (s.Capture + 1) % (len(g.displays) + 1)
+	g.scheduleSaveSettings()
+}
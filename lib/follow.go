@@ -0,0 +1,112 @@
+package lib
+
+import (
+	"errors"
+
+	"github.com/nvlled/screencage/lib/x11win"
+)
+
+// Redactions are drawn over the cage, they wouldn't stay over
+// the content of a followed window once it moves.
+var errFollowRedacted = errors.New("off while redactions are set, [R] then [delete] clears them")
+
+// followState is the window that the capture area follows,
+// instead of the cage.
+type followState struct {
+	conn   x11win.Conn
+	Window x11win.Window
+}
+
+// pickFollowWindow waits in the background for
+// a click on the window to follow.
+func (g *App) pickFollowWindow() {
+	task := &Task[*followState]{}
+	go func() {
+		defer task.Finish()
+		conn, err := x11win.Connect()
+		if err != nil {
+			task.Err = err
+			return
+		}
+		win, err := conn.Pick()
+		if err != nil {
+			conn.Close()
+			task.Err = err
+			return
+		}
+		task.Result = &followState{conn: conn, Window: win}
+	}()
+	g.followPick = task
+}
+
+// followWindow follows the first window with
+// a title or a class that contains query.
+func (g *App) followWindow(query string) error {
+	if len(g.settings.Redactions) > 0 {
+		return errFollowRedacted
+	}
+	conn, err := x11win.Connect()
+	if err != nil {
+		return err
+	}
+	win, err := conn.Find(query)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	g.stopFollowing()
+	g.follow = &followState{conn: conn, Window: win}
+	return nil
+}
+
+func (g *App) stopFollowing() {
+	if g.follow != nil {
+		g.follow.conn.Close()
+		g.follow = nil
+	}
+}
+
+// updateFollow checks if a window was picked, and
+// stops following once redactions are added.
+func (g *App) updateFollow() {
+	if task := g.followPick; task != nil && task.IsDone() {
+		g.followPick = nil
+		g.followErr = task.Err
+		if task.Err == nil {
+			g.stopFollowing()
+			g.follow = task.Result
+		}
+	}
+	if g.follow != nil && len(g.settings.Redactions) > 0 {
+		g.stopFollowing()
+		g.followErr = errFollowRedacted
+	}
+}
+
+// toggleFollow starts picking a window to follow,
+// or stops following it.
+func (g *App) toggleFollow() {
+	switch {
+	case g.followPick != nil:
+		// still waiting for a click
+	case g.follow != nil:
+		g.stopFollowing()
+	case len(g.settings.Redactions) > 0:
+		g.followErr = errFollowRedacted
+	default:
+		g.followErr = nil
+		g.pickFollowWindow()
+	}
+}
+
+func (g *App) followInfo() string {
+	switch {
+	case g.followPick != nil:
+		return "click on a window"
+	case g.follow != nil:
+		return g.follow.Window.String()
+	case g.followErr != nil:
+		return g.followErr.Error()
+	}
+	return "off"
+}
@@ -1,10 +1,19 @@
 package framerate
 
 import (
+	"encoding/json"
 	"fmt"
+	"math"
+	"math/big"
+	"math/bits"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 )
 
+// Unit is only used to read frame rates saved
+// in the old {"value": 5, "unit": 0} format.
 type Unit uint8
 
 const (
@@ -15,48 +24,219 @@ const (
 	Unit_End
 )
 
+// T is a frame rate of Frames frames every Seconds seconds,
+// so 2.5 frames per second is {5, 2}, and one frame
+// every 90 seconds is {1, 90}.
 type T struct {
-	Value int  `json:"value"`
-	Unit  Unit `json:"unit"`
+	Frames  int64
+	Seconds int64
 }
 
-func (rate *T) String() string {
-	unitStr := "seconds"
-	switch rate.Unit {
-	case UnitSecond:
-		unitStr = "seconds"
-	case UnitMinute:
-		unitStr = "minutes"
-	case UnitHour:
-		unitStr = "hours"
+func FPS(frames int64) T {
+	return T{Frames: frames, Seconds: 1}
+}
+
+// Every returns the rate of one frame every d, rounded to a second.
+func Every(d time.Duration) T {
+	return T{Frames: 1, Seconds: int64(d.Round(time.Second) / time.Second)}.reduce()
+}
+
+// the rates stepped through by Step, from the slowest
+var presets = []T{
+	Every(time.Hour), Every(10 * time.Minute), Every(5 * time.Minute),
+	Every(time.Minute), Every(30 * time.Second), Every(10 * time.Second),
+	Every(5 * time.Second), Every(2 * time.Second),
+	FPS(1), FPS(2), FPS(3), FPS(4), FPS(5), FPS(8), FPS(10),
+	FPS(12), FPS(15), FPS(20), FPS(24), FPS(25), FPS(30),
+}
+
+func (rate T) Valid() bool {
+	return rate.Frames > 0 && rate.Seconds > 0
+}
+
+// Parse parses a rate like "12fps", "2.5fps", "1/90s",
+// "5/2s", "2.5/s", "1/5m" or "1/h".
+func Parse(str string) (T, error) {
+	str = strings.TrimSpace(strings.ToLower(str))
+	invalid := fmt.Errorf("invalid frame rate: %q", str)
+
+	framesStr, per := str, "s"
+	if strings.HasSuffix(str, "fps") {
+		framesStr = strings.TrimSuffix(str, "fps")
+	} else if i := strings.IndexByte(str, '/'); i >= 0 {
+		framesStr, per = str[:i], str[i+1:]
+	} else {
+		return T{}, invalid
+	}
+
+	frames, ok := new(big.Rat).SetString(strings.TrimSpace(framesStr))
+	if !ok || frames.Sign() <= 0 {
+		return T{}, invalid
+	}
+
+	if per == "" {
+		return T{}, invalid
+	}
+	var unit int64
+	switch per[len(per)-1] {
+	case 's':
+		unit = 1
+	case 'm':
+		unit = 60
+	case 'h':
+		unit = 60 * 60
+	default:
+		return T{}, invalid
+	}
+	n := int64(1)
+	if numStr := per[:len(per)-1]; numStr != "" {
+		var err error
+		n, err = strconv.ParseInt(numStr, 10, 64)
+		if err != nil || n <= 0 {
+			return T{}, invalid
+		}
+	}
+
+	// frames per n units is frames.Num every frames.Denom * n * unit seconds
+	seconds := new(big.Int).Mul(frames.Denom(), big.NewInt(n))
+	seconds.Mul(seconds, big.NewInt(unit))
+	rate := new(big.Rat).SetFrac(frames.Num(), seconds)
+	if !rate.Num().IsInt64() || !rate.Denom().IsInt64() {
+		return T{}, fmt.Errorf("frame rate out of range: %q", str)
+	}
+	return T{Frames: rate.Num().Int64(), Seconds: rate.Denom().Int64()}, nil
+}
+
+// String formats the rate so that Parse can read it back.
+func (rate T) String() string {
+	if !rate.Valid() {
+		return "invalid"
+	}
+	if rate.Seconds == 1 {
+		return fmt.Sprintf("%vfps", rate.Frames)
+	}
+
+	per, unit := rate.Seconds, "s"
+	if rate.Seconds%(60*60) == 0 {
+		per, unit = rate.Seconds/(60*60), "h"
+	} else if rate.Seconds%60 == 0 {
+		per, unit = rate.Seconds/60, "m"
 	}
+	if per == 1 {
+		return fmt.Sprintf("%v/%v", rate.Frames, unit)
+	}
+	return fmt.Sprintf("%v/%v%v", rate.Frames, per, unit)
+}
 
-	return fmt.Sprintf("%v frames per %v", rate.Value, unitStr)
+func (rate T) MarshalJSON() ([]byte, error) {
+	return json.Marshal(rate.String())
 }
 
-func (rate *T) Increment()           { rate.Value++ }
-func (rate *T) Decrement()           { rate.Value-- }
-func (rate *T) IncrementBy(step int) { rate.Value += step }
-func (rate *T) DecrementBy(step int) { rate.Value -= step }
+// UnmarshalJSON reads a string like "12fps", or
+// the old format, {"value": 12, "unit": 0}.
+func (rate *T) UnmarshalJSON(data []byte) error {
+	var str string
+	if err := json.Unmarshal(data, &str); err == nil {
+		parsed, err := Parse(str)
+		if err != nil {
+			return err
+		}
+		*rate = parsed
+		return nil
+	}
 
-func (rate *T) Duration() time.Duration {
-	const one = float64(1)
-	value := float64(rate.Value)
-	switch rate.Unit {
-	case UnitSecond:
-		return time.Duration(one/value*1000*1000) * time.Microsecond
+	var legacy struct {
+		Value int64 `json:"value"`
+		Unit  Unit  `json:"unit"`
+	}
+	if err := json.Unmarshal(data, &legacy); err != nil {
+		return err
+	}
+	seconds := int64(1)
+	switch legacy.Unit {
 	case UnitMinute:
-		return time.Duration((one/(one/60*value))*1000*1000) * time.Microsecond
+		seconds = 60
 	case UnitHour:
-		return time.Duration((one/(one/60/60*value))*1000*1000) * time.Microsecond
+		seconds = 60 * 60
 	}
-	return 0
+	*rate = T{Frames: legacy.Value, Seconds: seconds}.reduce()
+	return nil
 }
 
-func (rate *T) Clamp(min, max int) {
-	if rate.Value < min {
-		rate.Value = min
-	} else if rate.Value > max {
-		rate.Value = max
+// FramesPerSecond returns the rate as a float, for display.
+func (rate T) FramesPerSecond() float64 {
+	if !rate.Valid() {
+		return 0
+	}
+	return float64(rate.Frames) / float64(rate.Seconds)
+}
+
+// Duration returns the time between two frames, rounded to
+// a nanosecond, or the longest duration if it doesn't fit.
+func (rate T) Duration() time.Duration {
+	if !rate.Valid() {
+		return 0
+	}
+	frames := uint64(rate.Frames)
+	hi, lo := bits.Mul64(uint64(rate.Seconds), uint64(time.Second))
+	if hi >= frames {
+		return math.MaxInt64
+	}
+	q, r := bits.Div64(hi, lo, frames)
+	if r >= frames-r {
+		q++
+	}
+	if q > math.MaxInt64 {
+		return math.MaxInt64
+	}
+	return time.Duration(q)
+}
+
+// Compare returns -1, 0 or 1 if rate is slower, equal or faster than other.
+func (rate T) Compare(other T) int {
+	a := new(big.Int).Mul(big.NewInt(rate.Frames), big.NewInt(other.Seconds))
+	b := new(big.Int).Mul(big.NewInt(other.Frames), big.NewInt(rate.Seconds))
+	return a.Cmp(b)
+}
+
+// Step returns the preset n steps faster than rate,
+// or slower if n is negative.
+func (rate T) Step(n int) T {
+	// number of presets slower than rate
+	i := sort.Search(len(presets), func(i int) bool {
+		return presets[i].Compare(rate) >= 0
+	})
+	if n > 0 && (i == len(presets) || presets[i].Compare(rate) != 0) {
+		// rate is between two presets, the next one is already a step
+		i--
+	}
+	i += n
+	if i < 0 {
+		i = 0
+	} else if i >= len(presets) {
+		i = len(presets) - 1
+	}
+	return presets[i]
+}
+
+// Clamp limits rate between min and max.
+func (rate T) Clamp(min, max T) T {
+	if !rate.Valid() || rate.Compare(min) < 0 {
+		return min
+	}
+	if rate.Compare(max) > 0 {
+		return max
+	}
+	return rate
+}
+
+func (rate T) reduce() T {
+	a, b := rate.Frames, rate.Seconds
+	for b != 0 {
+		a, b = b, a%b
+	}
+	if a <= 1 {
+		return rate
 	}
+	return T{Frames: rate.Frames / a, Seconds: rate.Seconds / a}
 }
@@ -0,0 +1,125 @@
+package framerate
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	for _, entry := range []struct {
+		str      string
+		expected T
+	}{
+		{"12fps", T{12, 1}},
+		{"2.5fps", T{5, 2}},
+		{"2.5/s", T{5, 2}},
+		{"1/90s", T{1, 90}},
+		{"5/2s", T{5, 2}},
+		{"4/2s", T{2, 1}},
+		{"1/5m", T{1, 300}},
+		{"1/h", T{1, 3600}},
+		{" 30FPS ", T{30, 1}},
+	} {
+		rate, err := Parse(entry.str)
+		if err != nil {
+			t.Errorf("%q: %v", entry.str, err)
+			continue
+		}
+		if rate != entry.expected {
+			t.Errorf("%q: expected=%v, got=%v", entry.str, entry.expected, rate)
+		}
+	}
+
+	for _, str := range []string{"", "12", "0fps", "-1fps", "1/0s", "1/s2", "x/s", "1/5d", "1/"} {
+		if rate, err := Parse(str); err == nil {
+			t.Errorf("%q: expected an error, got=%v", str, rate)
+		}
+	}
+}
+
+func TestString(t *testing.T) {
+	for _, rate := range []T{{12, 1}, {5, 2}, {1, 90}, {1, 300}, {1, 3600}, {1, 7200}, {7, 60}, {1, 60}} {
+		parsed, err := Parse(rate.String())
+		if err != nil || parsed != rate {
+			t.Errorf("%v: parsed back as %v, %v", rate.String(), parsed, err)
+		}
+	}
+	if str := (T{1, 90}).String(); str != "1/90s" {
+		t.Errorf("expected=1/90s, got=%v", str)
+	}
+	if str := (T{1, 300}).String(); str != "1/5m" {
+		t.Errorf("expected=1/5m, got=%v", str)
+	}
+}
+
+func TestJSON(t *testing.T) {
+	data, err := json.Marshal(T{5, 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `"5/2s"` {
+		t.Errorf("expected=\"5/2s\", got=%s", data)
+	}
+
+	for _, entry := range []struct {
+		json     string
+		expected T
+	}{
+		{`"12fps"`, T{12, 1}},
+		{`{"value": 5, "unit": 0}`, T{5, 1}},
+		{`{"value": 2, "unit": 1}`, T{1, 30}},
+		{`{"value": 1, "unit": 2}`, T{1, 3600}},
+	} {
+		var rate T
+		if err := json.Unmarshal([]byte(entry.json), &rate); err != nil {
+			t.Errorf("%v: %v", entry.json, err)
+		} else if rate != entry.expected {
+			t.Errorf("%v: expected=%v, got=%v", entry.json, entry.expected, rate)
+		}
+	}
+}
+
+func TestDuration(t *testing.T) {
+	for _, entry := range []struct {
+		rate     T
+		expected time.Duration
+	}{
+		{T{5, 2}, 400 * time.Millisecond},
+		{T{1, 90}, 90 * time.Second},
+		{T{30, 1}, 33333333 * time.Nanosecond},
+		{T{3, 1}, 333333333 * time.Nanosecond},
+		{T{3, 2}, 666666667 * time.Nanosecond},
+		{T{1, math.MaxInt64}, math.MaxInt64},
+		{T{math.MaxInt64, math.MaxInt64}, time.Second},
+		{T{0, 1}, 0},
+	} {
+		if d := entry.rate.Duration(); d != entry.expected {
+			t.Errorf("%v: expected=%v, got=%v", entry.rate, entry.expected, d)
+		}
+	}
+}
+
+func TestStep(t *testing.T) {
+	for _, entry := range []struct {
+		rate     T
+		n        int
+		expected T
+	}{
+		{FPS(5), 1, FPS(8)},
+		{FPS(5), -1, FPS(4)},
+		{FPS(1), -1, Every(2 * time.Second)},
+		{T{5, 2}, 1, FPS(3)},
+		{T{5, 2}, -1, FPS(2)},
+		{FPS(30), 1, FPS(30)},
+		{FPS(60), -1, FPS(30)},
+		{Every(time.Hour), -1, Every(time.Hour)},
+		{Every(2 * time.Hour), 1, Every(time.Hour)},
+		{FPS(5), 5, FPS(20)},
+	} {
+		if rate := entry.rate.Step(entry.n); rate != entry.expected {
+			t.Errorf("%v step %v: expected=%v, got=%v", entry.rate, entry.n, entry.expected, rate)
+		}
+	}
+}
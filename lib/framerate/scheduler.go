@@ -0,0 +1,120 @@
+package framerate
+
+import (
+	"fmt"
+	"time"
+)
+
+// Clock tells the time, it's replaced with a fake one in tests.
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+var SystemClock Clock = systemClock{}
+
+// Scheduler keeps the frames on a fixed timeline. The ticks are at
+// absolute times, start + n*interval, so that the time spent capturing
+// doesn't add up. When a capture is late, the missed ticks are dropped
+// and merged into the next frame.
+type Scheduler struct {
+	Interval time.Duration
+
+	clock   Clock
+	start   time.Time
+	started bool
+
+	// index of the next tick
+	next  int64
+	stats Stats
+}
+
+type Stats struct {
+	Frames int
+
+	// ticks skipped because a capture was late
+	Dropped int
+
+	// from the first frame to the last one
+	Elapsed time.Duration
+
+	Interval time.Duration
+}
+
+// Rate returns the achieved frames per second.
+func (stats Stats) Rate() float64 {
+	if stats.Frames < 2 || stats.Elapsed <= 0 {
+		return 0
+	}
+	return float64(stats.Frames-1) / stats.Elapsed.Seconds()
+}
+
+// TargetRate returns the frames per second of the schedule.
+func (stats Stats) TargetRate() float64 {
+	if stats.Interval <= 0 {
+		return 0
+	}
+	return float64(time.Second) / float64(stats.Interval)
+}
+
+func (stats Stats) String() string {
+	return fmt.Sprintf("%v frames, %v dropped, %.2f of %.2f fps",
+		stats.Frames, stats.Dropped, stats.Rate(), stats.TargetRate())
+}
+
+// NewScheduler creates a scheduler that ticks every interval.
+// A nil clock uses the system time.
+func NewScheduler(interval time.Duration, clock Clock) *Scheduler {
+	if clock == nil {
+		clock = SystemClock
+	}
+	if interval <= 0 {
+		interval = time.Millisecond
+	}
+	return &Scheduler{Interval: interval, clock: clock}
+}
+
+// Wait returns how long to wait until the next tick,
+// zero if it's already late.
+func (s *Scheduler) Wait() time.Duration {
+	if !s.started {
+		return 0
+	}
+	d := s.start.Add(time.Duration(s.next) * s.Interval).Sub(s.clock.Now())
+	if d < 0 {
+		return 0
+	}
+	return d
+}
+
+// Tick is called right before capturing a frame. It returns the
+// time of the frame on the timeline, the first one is at zero.
+func (s *Scheduler) Tick() time.Duration {
+	now := s.clock.Now()
+	if !s.started {
+		s.started = true
+		s.start = now
+		s.next = 1
+		s.stats = Stats{Frames: 1, Interval: s.Interval}
+		return 0
+	}
+
+	current := int64(now.Sub(s.start) / s.Interval)
+	if current < s.next {
+		// woke up a little early
+		current = s.next
+	}
+	s.stats.Dropped += int(current - s.next)
+	s.stats.Frames++
+	s.stats.Elapsed = now.Sub(s.start)
+	s.next = current + 1
+
+	return time.Duration(current) * s.Interval
+}
+
+func (s *Scheduler) Stats() Stats {
+	return s.stats
+}
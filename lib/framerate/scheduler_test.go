@@ -0,0 +1,85 @@
+package framerate
+
+import (
+	"testing"
+	"time"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (clock *fakeClock) Now() time.Time { return clock.now }
+
+func (clock *fakeClock) Advance(d time.Duration) { clock.now = clock.now.Add(d) }
+
+func TestScheduler(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	interval := 100 * time.Millisecond
+	s := NewScheduler(interval, clock)
+
+	if d := s.Wait(); d != 0 {
+		t.Errorf("first wait: expected=0, got=%v", d)
+	}
+
+	var frames []time.Duration
+	capture := func(captureTime time.Duration) {
+		clock.Advance(s.Wait())
+		frames = append(frames, s.Tick())
+		clock.Advance(captureTime)
+	}
+
+	// capturing takes time, but the ticks don't drift
+	for i := 0; i < 5; i++ {
+		capture(30 * time.Millisecond)
+	}
+	if d := s.Wait(); d != 70*time.Millisecond {
+		t.Errorf("wait: expected=70ms, got=%v", d)
+	}
+
+	// a slow capture misses the tick at 600ms,
+	// the next frame is late and counts for 700ms
+	capture(250 * time.Millisecond)
+	capture(10 * time.Millisecond)
+
+	expected := []time.Duration{0, 100, 200, 300, 400, 500, 700}
+	if len(frames) != len(expected) {
+		t.Fatalf("expected=%v, got=%v", expected, frames)
+	}
+	for i := range frames {
+		if frames[i] != expected[i]*time.Millisecond {
+			t.Errorf("frame %v: expected=%v, got=%v", i, expected[i]*time.Millisecond, frames[i])
+		}
+	}
+
+	stats := s.Stats()
+	if stats.Frames != 7 || stats.Dropped != 1 {
+		t.Errorf("stats: expected 7 frames and 1 dropped, got=%v", stats)
+	}
+	if stats.Elapsed != 750*time.Millisecond {
+		t.Errorf("elapsed: expected=750ms, got=%v", stats.Elapsed)
+	}
+	if rate := stats.Rate(); rate != 8 {
+		t.Errorf("rate: expected=8, got=%v", rate)
+	}
+	if rate := stats.TargetRate(); rate != 10 {
+		t.Errorf("target rate: expected=10, got=%v", rate)
+	}
+}
+
+func TestSchedulerEarlyWakeUp(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	s := NewScheduler(time.Second, clock)
+	s.Tick()
+
+	clock.Advance(999 * time.Millisecond)
+	if at := s.Tick(); at != time.Second {
+		t.Errorf("expected=1s, got=%v", at)
+	}
+	if s.Stats().Dropped != 0 {
+		t.Errorf("expected no dropped ticks, got=%v", s.Stats().Dropped)
+	}
+	if d := s.Wait(); d != 1001*time.Millisecond {
+		t.Errorf("wait: expected=1.001s, got=%v", d)
+	}
+}
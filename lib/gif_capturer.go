@@ -1,6 +1,7 @@
 package lib
 
 import (
+	"errors"
 	"image"
 	"image/color"
 	"image/draw"
@@ -12,15 +13,20 @@ import (
 	"github.com/ericpauley/go-quantize/quantize"
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/hajimehoshi/ebiten/v2/inpututil"
-	"github.com/kbinani/screenshot"
 	"github.com/nvlled/carrot"
 	gif "github.com/nvlled/gogif"
 	"github.com/nvlled/screencage/lib/framerate"
+	"github.com/nvlled/screencage/lib/gifedit"
+	"github.com/nvlled/screencage/lib/x11win"
 )
 
 type GifCapturer struct {
 	saveFilename string
 
+	// the files of the last recording, one
+	// per burst with MotionSplit
+	savedFiles []string
+
 	numImages    int
 	numProcessed int
 
@@ -35,6 +41,23 @@ type GifCapturer struct {
 
 	lastDraw int64
 
+	trimmed bool
+
+	// of the last recording
+	stats framerate.Stats
+
+	// with motion-triggered capture, the number of bursts
+	// of motion so far, and whether one is going on
+	numBursts    int
+	motionActive bool
+
+	// in replay mode, the buffer, the length it holds,
+	// and the number of saved replays
+	replay        *Queue[ReplayFrame]
+	replayLength  time.Duration
+	numReplays    int
+	replaySavedAt time.Time
+
 	Err error
 }
 
@@ -50,6 +73,9 @@ func NewGifCapturer(game *App) *GifCapturer {
 type GifFrame struct {
 	Image   *image.RGBA
 	CsDelay int
+
+	// NewBurst starts a new file, for one file per burst of motion.
+	NewBurst bool
 }
 
 func (capturer *GifCapturer) coroutine(ctrl *carrot.Control) {
@@ -70,7 +96,11 @@ START:
 		for {
 			if inpututil.IsKeyJustPressed(ebiten.KeyEnter) || capturer.game.autoStart {
 				capturer.game.autoStart = false
-				capturer.Err = capturer.startRecording(ctrl)
+				if capturer.game.settings.Replay.Enabled {
+					capturer.Err = capturer.startReplay(ctrl)
+				} else {
+					capturer.Err = capturer.startRecording(ctrl)
+				}
 
 				if capturer.game.exitOnFinish {
 					println(capturer.saveFilename)
@@ -98,18 +128,32 @@ ERROR:
 }
 
 func (capturer *GifCapturer) startRecording(ctrl *carrot.Control) error {
-	capturer.saveFilename, _ =
-		capturer.game.getNextOutFilename()
-
-	file, err := os.OpenFile(capturer.saveFilename, os.O_CREATE|os.O_WRONLY, 0644)
+	output, err := capturer.game.createPendingOutput()
 	if err != nil {
 		return err
 	}
-	defer file.Close()
-	encoder := gif.NewStreamEncoder(file, &gif.StreamEncoderOptions{})
+	capturer.saveFilename = output.Target
+	capturer.savedFiles = nil
+	encoder := gif.NewStreamEncoder(output, &gif.StreamEncoderOptions{})
 
-	// recording
 	var encodingCtrl carrot.SubControl
+	var encodingTask *Task[Void]
+	// the output is committed, or discarded when there is nothing to save
+	finished := false
+	defer func() {
+		if finished {
+			return
+		}
+		if encodingCtrl != nil {
+			encodingCtrl.Cancel()
+		}
+		if encodingTask != nil {
+			ctrl.YieldUntil(encodingTask.IsDone)
+		}
+		capturer.abortRecording(output, encoder)
+	}()
+
+	// recording
 	log.Println("* start recording")
 	{
 		capturer.numImages = 0
@@ -125,6 +169,7 @@ func (capturer *GifCapturer) startRecording(ctrl *carrot.Control) error {
 		awaitNextDraw(ctrl, &capturer.lastDraw)
 
 		queue := CreateQueue[GifFrame](128)
+		reserved := capturer.game.reservedPalette()
 
 		var err error
 
@@ -133,13 +178,23 @@ func (capturer *GifCapturer) startRecording(ctrl *carrot.Control) error {
 		})
 
 		encodingCtrl = ctrl.StartAsync(func(ctrl *carrot.Control) {
+			// frames in the current file
+			numEncoded := 0
 			for !queue.IsEmpty() || !screenShotCtrl.IsDone() {
 				frame, ok := queue.Pop()
 				if !ok {
 					ctrl.Yield()
 					continue
 				}
-				task := SaveOneGif(encoder, frame.Image, frame.CsDelay)
+				if frame.NewBurst && numEncoded > 0 {
+					output, encoder, err = capturer.nextBurstFile(output, encoder)
+					if err != nil {
+						return
+					}
+					numEncoded = 0
+				}
+				task := SaveOneGif(encoder, frame.Image, frame.CsDelay, reserved)
+				encodingTask = task
 				ctrl.YieldUntil(task.IsDone)
 
 				if task.Err != nil {
@@ -148,16 +203,22 @@ func (capturer *GifCapturer) startRecording(ctrl *carrot.Control) error {
 				}
 
 				capturer.numProcessed++
+				numEncoded++
 			}
 		})
 
-		awaitEnter(ctrl)
-		for !inpututil.IsKeyJustPressed(ebiten.KeyEnter) {
+		// the capture loop also stops by itself
+		// when the followed window is closed
+		ctrl.Yield()
+		for !inpututil.IsKeyJustPressed(ebiten.KeyEnter) && !screenShotCtrl.IsDone() {
 			if err != nil {
 				return err
 			}
 			ctrl.Yield()
 		}
+		if err != nil {
+			return err
+		}
 
 		screenShotCtrl.Cancel()
 
@@ -184,18 +245,46 @@ func (capturer *GifCapturer) startRecording(ctrl *carrot.Control) error {
 				break
 			}
 		}
+		if capturer.numImages == 0 && capturer.game.settings.Motion.Enabled {
+			log.Println("* no motion, nothing saved")
+			encoder.Close()
+			if err := output.Discard(); err != nil {
+				log.Println("failed to remove", output.Name(), err)
+			}
+			finished = true
+
+			capturer.draw = capturer.drawNothingSaved
+			now := time.Now()
+			ctrl.Yield()
+			ctrl.YieldUntil(func() bool {
+				return inpututil.IsKeyJustPressed(ebiten.KeyEnter) || time.Since(now).Seconds() > 4
+			})
+			return nil
+		}
 		if err := encoder.Close(); err != nil {
 			return err
 		}
+		if err := output.Commit(); err != nil {
+			return err
+		}
+		finished = true
+		capturer.savedFiles = append(capturer.savedFiles, output.Target)
 	}
 
 	// saved
 	log.Println("* saved")
 	{
+		capturer.trimmed = false
 		capturer.draw = capturer.drawSaved
 		now := time.Now()
 		for {
 			ctrl.Yield()
+			if inpututil.IsKeyJustPressed(ebiten.KeyT) && !capturer.trimmed {
+				if err := capturer.trimSaved(ctrl); err != nil {
+					return err
+				}
+				now = time.Now()
+			}
 			if inpututil.IsKeyJustPressed(ebiten.KeyEnter) || time.Since(now).Seconds() > 2 {
 				break
 			}
@@ -205,28 +294,150 @@ func (capturer *GifCapturer) startRecording(ctrl *carrot.Control) error {
 	return nil
 }
 
+// trimSaved drops the start and end of the saved recording, which
+// is usually just fiddling with the window. With MotionSplit, each
+// burst is trimmed, except those too short to trim.
+func (capturer *GifCapturer) trimSaved(ctrl *carrot.Control) error {
+	log.Println("* trimming")
+	capturer.draw = capturer.drawTrimming
+	defer func() {
+		capturer.trimmed = true
+		capturer.draw = capturer.drawSaved
+	}()
+
+	opts := capturer.game.settings.Trim.Options()
+	files := capturer.savedFiles
+	for _, filename := range files {
+		capturer.saveFilename = filename
+		task := &Task[Void]{}
+		go func() {
+			defer task.Finish()
+			_, task.Err = TrimGifFile(filename, "", opts)
+		}()
+		ctrl.YieldUntil(task.IsDone)
+
+		if errors.Is(task.Err, gifedit.ErrEmptyResult) && len(files) > 1 {
+			log.Println("* burst too short to trim:", filename)
+			continue
+		}
+		if task.Err != nil {
+			return task.Err
+		}
+	}
+	return nil
+}
+
+// nextBurstFile saves the gif of the previous burst of motion,
+// and starts the next one in a new file next to it.
+func (capturer *GifCapturer) nextBurstFile(output *PendingFile, encoder *gif.StreamEncoder) (*PendingFile, *gif.StreamEncoder, error) {
+	file, err := CreateNextIncrementedFile(capturer.saveFilename)
+	if err != nil {
+		return output, encoder, err
+	}
+	file.Close()
+	target := file.Name()
+	next, err := CreatePendingFile(target, true)
+	if err != nil {
+		os.Remove(target)
+		return output, encoder, err
+	}
+	if err := encoder.Close(); err != nil {
+		next.Discard()
+		return output, encoder, err
+	}
+	if err := output.Commit(); err != nil {
+		next.Discard()
+		return output, encoder, err
+	}
+	log.Println("* saved burst to", output.Target)
+	capturer.savedFiles = append(capturer.savedFiles, output.Target)
+
+	capturer.saveFilename = target
+	return next, gif.NewStreamEncoder(next, &gif.StreamEncoderOptions{}), nil
+}
+
+// abortRecording removes the unfinished recording,
+// or keeps it as a *.partial.gif if the settings say so.
+func (capturer *GifCapturer) abortRecording(output *PendingFile, encoder *gif.StreamEncoder) {
+	encoder.Close()
+
+	if capturer.game.settings.KeepPartialOnError {
+		if info, err := os.Stat(output.Name()); err == nil && info.Size() > 0 {
+			filename, err := output.Salvage()
+			if err == nil {
+				log.Println("* saved partial recording to", filename)
+				return
+			}
+			log.Println("failed to save partial recording:", err)
+		}
+	}
+
+	if err := output.Discard(); err != nil {
+		log.Println("failed to remove", output.Name(), err)
+	}
+}
+
 func (capturer *GifCapturer) startScreenShotLoop(queue *Queue[GifFrame], ctrl *carrot.Control) error {
-	lastShot := time.Now()
-	rate := capturer.game.settings.FrameRate
-	frameDuration := rate.Duration()
-	for {
-		bounds := GetWindowBounds()
+	capturer.game.resetFrameCount()
+	s := capturer.game.settings
+	rate := s.FrameRate
+	sched := framerate.NewScheduler(rate.Duration(), nil)
 
-		img, err := screenshot.CaptureRect(bounds)
+	var motion *MotionDetector
+	if s.Motion.Enabled {
+		motion = NewMotionDetector(s.Motion)
+	}
+	capturer.numBursts = 0
+	capturer.motionActive = false
+	intervalCs := int(s.Timelapse.PlaybackTime(rate.Duration(), rate) / (10 * time.Millisecond))
+
+	prevCs := 0
+	for {
+		at := awaitTick(ctrl, sched)
+		capturer.stats = sched.Stats()
+		img, area, err := capturer.game.captureRaw()
+		if errors.Is(err, x11win.ErrWindowClosed) {
+			log.Println("* followed window closed")
+			return nil
+		}
 		if err != nil {
 			return err
 		}
 
-		delay := int(time.Since(lastShot).Milliseconds() / 10)
+		// motion is detected before the overlays are drawn,
+		// so that the cursor or keystrokes don't count
+		newBurst := false
+		if motion != nil {
+			record, start := motion.Update(img, at)
+			capturer.motionActive = record
+			if !record {
+				continue
+			}
+			newBurst = start
+		}
+		img = capturer.game.processFrame(img, area)
+
+		// from the timeline, so that rounding doesn't add up
+		at = s.Timelapse.PlaybackTime(at, rate)
+		cs := int(at / (10 * time.Millisecond))
+		if newBurst {
+			// the time without motion is left out
+			capturer.numBursts++
+			prevCs = cs - intervalCs
+		}
+		delay := cs - prevCs
 		if delay > 500 {
 			delay = 500
 		}
+		prevCs = cs
 		capturer.numImages++
 		log.Println("* screenshot", capturer.numImages, delay)
 
-		queue.Push(GifFrame{Image: img, CsDelay: delay})
-		lastShot = time.Now()
-		ctrl.Sleep(frameDuration)
+		queue.Push(GifFrame{
+			Image:    img,
+			CsDelay:  delay,
+			NewBurst: newBurst && s.Motion.Output == MotionSplit,
+		})
 	}
 }
 
@@ -242,20 +453,54 @@ func (capturer *GifCapturer) Update() {
 
 		stepSize := 1
 		if ebiten.IsKeyPressed(ebiten.KeyShift) {
-			stepSize = 10
+			stepSize = 5
 		}
 		if inpututil.IsKeyJustPressed(ebiten.KeyDown) {
-			s.FrameRate.Value -= stepSize
+			s.FrameRate = s.FrameRate.Step(-stepSize)
 			capturer.game.scheduleSaveSettings()
 		} else if inpututil.IsKeyJustPressed(ebiten.KeyUp) {
-			s.FrameRate.Value += stepSize
+			s.FrameRate = s.FrameRate.Step(stepSize)
 			capturer.game.scheduleSaveSettings()
 		}
-		s.FrameRate.Clamp(1, 30)
-
-		if inpututil.IsKeyJustPressed(ebiten.KeyBackspace) {
-			s.FrameRate.Unit = (s.FrameRate.Unit + 1) % framerate.Unit_End
+		if inpututil.IsKeyJustPressed(ebiten.KeyL) {
+			s.Timelapse.Enabled = !s.Timelapse.Enabled
+			s.Replay.Enabled = s.Replay.Enabled && !s.Timelapse.Enabled
+			capturer.game.scheduleSaveSettings()
 		}
+		if inpututil.IsKeyJustPressed(ebiten.KeyB) {
+			s.Replay.Enabled = !s.Replay.Enabled
+			s.replayOnly()
+			capturer.game.scheduleSaveSettings()
+		}
+		if s.Replay.Enabled {
+			if inpututil.IsKeyJustPressed(ebiten.KeyBracketLeft) {
+				s.Replay = s.Replay.Step(-1)
+				capturer.game.scheduleSaveSettings()
+			} else if inpututil.IsKeyJustPressed(ebiten.KeyBracketRight) {
+				s.Replay = s.Replay.Step(1)
+				capturer.game.scheduleSaveSettings()
+			}
+		}
+		if inpututil.IsKeyJustPressed(ebiten.KeyM) {
+			s.Motion.Enabled = !s.Motion.Enabled
+			s.Replay.Enabled = s.Replay.Enabled && !s.Motion.Enabled
+			capturer.game.scheduleSaveSettings()
+		}
+		if inpututil.IsKeyJustPressed(ebiten.KeyO) && s.Motion.Enabled {
+			s.Motion.Output = (s.Motion.Output + 1) % MotionOutput_Size
+			capturer.game.scheduleSaveSettings()
+		}
+		if s.Timelapse.Enabled {
+			if inpututil.IsKeyJustPressed(ebiten.KeyLeft) {
+				s.Timelapse.PlaybackRate = s.Timelapse.PlaybackRate.Step(-1)
+				capturer.game.scheduleSaveSettings()
+			} else if inpututil.IsKeyJustPressed(ebiten.KeyRight) {
+				s.Timelapse.PlaybackRate = s.Timelapse.PlaybackRate.Step(1)
+				capturer.game.scheduleSaveSettings()
+			}
+			s.Timelapse.PlaybackRate = s.Timelapse.PlaybackRate.Clamp(framerate.FPS(1), framerate.FPS(30))
+		}
+		capturer.game.adjustFrameRate()
 	}
 }
 
@@ -270,8 +515,21 @@ func (capturer *GifCapturer) drawInactive(screen *ebiten.Image) {
 	scrp.Font = capturer.game.smallFont
 	scrp.Println("\n\n")
 	scrp.Printf("rate: %v", s.FrameRate.String())
-	scrp.Printf("controls: [up][down] or [backspace]")
+	scrp.Printf("controls: [up][down]")
 	scrp.Printf("shift can be used [up][down]")
+	scrp.Printf("timelapse [l]: %v", s.Timelapse.Describe(s.FrameRate))
+	if s.Timelapse.Enabled {
+		scrp.Printf("playback rate: [left][right]")
+	}
+	scrp.Printf("replay [b]: %v", s.Replay)
+	if s.Replay.Enabled {
+		scrp.Printf("length: [[][]], save: %v", replaySaveHotkey)
+		scrp.Printf("gif only, no timelapse or motion")
+	}
+	scrp.Printf("motion only [m]: %v", s.Motion)
+	if s.Motion.Enabled {
+		scrp.Printf("output: [o]")
+	}
 	scrp.Font = capturer.game.tinyFont
 
 	scrp.Println("\n\n")
@@ -288,6 +546,13 @@ func (capturer *GifCapturer) drawActive(screen *ebiten.Image) {
 	capturer.scrp.Println("Press [enter] to stop")
 	scrp.Font = capturer.game.smallFont
 	capturer.scrp.Printf("number of images: %v", capturer.numImages)
+	if capturer.game.settings.Motion.Enabled {
+		if capturer.motionActive {
+			scrp.Printf("motion burst %v", capturer.numBursts)
+		} else {
+			scrp.Printf("waiting for motion, %v bursts so far", capturer.numBursts)
+		}
+	}
 
 	scrp.Println("\n\n")
 	scrp.Font = capturer.game.smallFont
@@ -306,10 +571,40 @@ func (capturer *GifCapturer) drawSaving(screen *ebiten.Image) {
 
 func (capturer *GifCapturer) drawSaved(screen *ebiten.Image) {
 	scrp := capturer.scrp
+	trim := capturer.game.settings.Trim
 	scrp.Color = ColorWhite
-	capturer.scrp.Println("Done!")
+	if capturer.trimmed {
+		capturer.scrp.Println("Trimmed!")
+	} else {
+		capturer.scrp.Println("Done!")
+	}
 	scrp.Color = ColorWhite
 	capturer.scrp.Println("Press [enter] to continue")
+	scrp.Font = capturer.game.smallFont
+	scrp.Println(capturer.stats.String())
+
+	if !capturer.trimmed {
+		scrp.Font = capturer.game.smallFont
+		scrp.Println("\n")
+		scrp.Printf("Press [t] to trim %vs from the start\nand %vs from the end", trim.Start, trim.End)
+	}
+}
+
+func (capturer *GifCapturer) drawNothingSaved(screen *ebiten.Image) {
+	scrp := capturer.scrp
+	scrp.Color = ColorWhite
+	capturer.scrp.Println("Nothing saved")
+	capturer.scrp.Println("Press [enter] to continue")
+	scrp.Font = capturer.game.smallFont
+	scrp.Println("no motion was detected")
+}
+
+func (capturer *GifCapturer) drawTrimming(screen *ebiten.Image) {
+	scrp := capturer.scrp
+	scrp.Color = ColorWhite
+	capturer.scrp.Printf("Trimming %v\n", capturer.saveFilename)
+	scrp.Font = capturer.game.smallFont
+	capturer.scrp.Println("Please wait")
 }
 
 func (capturer *GifCapturer) drawError(screen *ebiten.Image) {
@@ -330,6 +625,9 @@ func (capturer *GifCapturer) Draw(screen *ebiten.Image) {
 	if capturer.draw != nil && !capturer.game.borderOnly {
 		capturer.draw(screen)
 	}
+	if capturer.replay != nil {
+		capturer.drawReplayFill(screen)
+	}
 
 	/*
 		if capturer.game.borderOnly && capturer.running.Load() {
@@ -353,20 +651,25 @@ func (capturer *GifCapturer) Draw(screen *ebiten.Image) {
 	*/
 }
 
-func SaveOneGif(encoder *gif.StreamEncoder, img *image.RGBA, delay int) *Task[Void] {
+// SaveOneGif quantizes img and adds it to the gif. The reserved
+// colors are always included in the palette of the frame.
+func SaveOneGif(encoder *gif.StreamEncoder, img *image.RGBA, delay int, reserved color.Palette) *Task[Void] {
 	task := &Task[Void]{}
 	go func() {
 		defer task.Finish()
-
-		quantizer := quantize.MedianCutQuantizer{}
-		emptyPalette := make([]color.Color, 0, 256)
-		pal := quantizer.Quantize(emptyPalette, img)
-
-		palleted := image.NewPaletted(img.Rect, pal)
-		draw.Src.Draw(palleted, img.Bounds(), img, image.Point{})
-
-		encoder.Encode(palleted, delay, gif.DisposalNone)
+		encoder.Encode(quantizeFrame(img, reserved), delay, gif.DisposalNone)
 	}()
 
 	return task
 }
+
+func quantizeFrame(img *image.RGBA, reserved color.Palette) *image.Paletted {
+	quantizer := quantize.MedianCutQuantizer{}
+	initPalette := make([]color.Color, 0, 256)
+	initPalette = append(initPalette, reserved...)
+	pal := quantizer.Quantize(initPalette, img)
+
+	palleted := image.NewPaletted(img.Rect, pal)
+	draw.Src.Draw(palleted, img.Bounds(), img, image.Point{})
+	return palleted
+}
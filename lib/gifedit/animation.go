@@ -0,0 +1,113 @@
+package gifedit
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"io"
+
+	"github.com/ericpauley/go-quantize/quantize"
+	gif "github.com/nvlled/gogif"
+)
+
+// Frame is a single image of an animation.
+type Frame struct {
+	Image *image.Paletted
+
+	// Delay in 100ths of a second.
+	Delay int
+
+	Disposal byte
+}
+
+type Animation struct {
+	Width     int
+	Height    int
+	LoopCount int
+	Frames    []Frame
+}
+
+func Decode(r io.Reader) (*Animation, error) {
+	g, err := gif.DecodeAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	anim := &Animation{
+		Width:     g.Config.Width,
+		Height:    g.Config.Height,
+		LoopCount: g.LoopCount,
+		Frames:    make([]Frame, len(g.Image)),
+	}
+	for i, img := range g.Image {
+		anim.Frames[i] = Frame{Image: img, Delay: g.Delay[i]}
+		if i < len(g.Disposal) {
+			anim.Frames[i].Disposal = g.Disposal[i]
+		}
+	}
+
+	return anim, nil
+}
+
+// Encode writes the animation with the same stream
+// encoder that is used for recording.
+func (anim *Animation) Encode(w io.Writer) error {
+	encoder := gif.NewStreamEncoder(w, &gif.StreamEncoderOptions{
+		LoopCount: anim.LoopCount,
+		Config:    image.Config{Width: anim.Width, Height: anim.Height},
+	})
+	for _, frame := range anim.Frames {
+		if err := encoder.Encode(frame.Image, frame.Delay, frame.Disposal); err != nil {
+			return err
+		}
+	}
+	return encoder.Close()
+}
+
+// Duration returns the total delay of the animation in 100ths of a second.
+func (anim *Animation) Duration() int {
+	total := 0
+	for _, frame := range anim.Frames {
+		total += frame.Delay
+	}
+	return total
+}
+
+func (anim *Animation) bounds() image.Rectangle {
+	return image.Rect(0, 0, anim.Width, anim.Height)
+}
+
+// coalesce returns frame i as a full image, with all the previous
+// frames drawn below it. This is needed when the frames before it are
+// dropped. Frames that already cover the whole animation are
+// returned as is.
+func (anim *Animation) coalesce(i int) Frame {
+	frame := anim.Frames[i]
+	bounds := anim.bounds()
+	if frame.Image.Bounds().Eq(bounds) && frame.Disposal != gif.DisposalPrevious {
+		return frame
+	}
+
+	canvas := image.NewRGBA(bounds)
+	for j := 0; j < i; j++ {
+		prev := anim.Frames[j]
+		switch prev.Disposal {
+		case gif.DisposalBackground:
+			draw.Draw(canvas, prev.Image.Bounds(), image.Transparent, image.Point{}, draw.Src)
+		case gif.DisposalPrevious:
+			// the canvas is left as it was before the frame
+		default:
+			draw.Draw(canvas, prev.Image.Bounds(), prev.Image, prev.Image.Bounds().Min, draw.Over)
+		}
+	}
+	draw.Draw(canvas, frame.Image.Bounds(), frame.Image, frame.Image.Bounds().Min, draw.Over)
+
+	quantizer := quantize.MedianCutQuantizer{AddTransparent: true}
+	pal := quantizer.Quantize(make([]color.Color, 0, 256), canvas)
+	palleted := image.NewPaletted(bounds, pal)
+	draw.Src.Draw(palleted, bounds, canvas, image.Point{})
+
+	frame.Image = palleted
+	frame.Disposal = gif.DisposalNone
+	return frame
+}
@@ -0,0 +1,2 @@
+// editing and recovery of gif files written by the capturers.
+package gifedit
@@ -0,0 +1,186 @@
+package gifedit
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	gif "github.com/nvlled/gogif"
+)
+
+func encodeTestGif(t *testing.T, delays []int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	encoder := gif.NewStreamEncoder(&buf, &gif.StreamEncoderOptions{})
+	pal := color.Palette{color.Black, color.White, color.RGBA{255, 0, 0, 255}}
+	for i, delay := range delays {
+		img := image.NewPaletted(image.Rect(0, 0, 16, 8), pal)
+		img.SetColorIndex(i%16, i%8, uint8(i%len(pal)))
+		if err := encoder.Encode(img, delay, gif.DisposalNone); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := encoder.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestRepair(t *testing.T) {
+	data := encodeTestGif(t, []int{10, 20, 30, 40})
+
+	if _, _, err := Repair(data); err != ErrNotDamaged {
+		t.Errorf("expected ErrNotDamaged, got %v", err)
+	}
+
+	complete, err := gif.DecodeAll(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// cut the file at every possible position after the second frame
+	firstCut := -1
+	for cut := len(data) - 2; cut > 0; cut-- {
+		repaired, info, err := Repair(data[:cut])
+		if err == ErrNoFrames {
+			firstCut = cut
+			break
+		}
+		if err != nil {
+			t.Fatalf("cut at %v: %v", cut, err)
+		}
+
+		g, err := gif.DecodeAll(bytes.NewReader(repaired))
+		if err != nil {
+			t.Fatalf("cut at %v: repaired gif does not decode: %v", cut, err)
+		}
+		if len(g.Image) != info.NumFrames {
+			t.Errorf("cut at %v: wrong number of frames, expected=%v, got=%v", cut, info.NumFrames, len(g.Image))
+		}
+		if info.NumFrames >= len(complete.Image) {
+			t.Errorf("cut at %v: incomplete frame was kept", cut)
+		}
+		for i := range g.Image {
+			if g.Delay[i] != complete.Delay[i] {
+				t.Errorf("cut at %v: wrong delay, expected=%v, got=%v", cut, complete.Delay[i], g.Delay[i])
+			}
+		}
+	}
+
+	if firstCut < 0 {
+		t.Error("expected ErrNoFrames for a file without frames")
+	}
+
+	if _, _, err := Repair([]byte("not a gif")); err != ErrNotGif {
+		t.Errorf("expected ErrNotGif, got %v", err)
+	}
+}
+
+func decodeTestGif(t *testing.T, delays []int) *Animation {
+	t.Helper()
+	anim, err := Decode(bytes.NewReader(encodeTestGif(t, delays)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return anim
+}
+
+func frameDelays(anim *Animation) []int {
+	var delays []int
+	for _, frame := range anim.Frames {
+		delays = append(delays, frame.Delay)
+	}
+	return delays
+}
+
+func TestTrim(t *testing.T) {
+	for _, entry := range []struct {
+		opts     TrimOptions
+		expected []int
+	}{
+		{TrimOptions{}, []int{0, 10, 20, 30, 40}},
+		{TrimOptions{Start: 100 * time.Millisecond}, []int{20, 30, 40}},
+		{TrimOptions{Start: 150 * time.Millisecond}, []int{15, 30, 40}},
+		{TrimOptions{End: 400 * time.Millisecond}, []int{0, 10, 20, 30}},
+		{TrimOptions{End: 500 * time.Millisecond}, []int{0, 10, 20, 20}},
+		{TrimOptions{Start: 50 * time.Millisecond, End: 50 * time.Millisecond}, []int{5, 20, 30, 35}},
+		{TrimOptions{From: 1, To: 3}, []int{10, 20}},
+		{TrimOptions{From: 3}, []int{30, 40}},
+		{TrimOptions{From: 1, Start: 100 * time.Millisecond}, []int{20, 30, 40}},
+	} {
+		anim := decodeTestGif(t, []int{0, 10, 20, 30, 40})
+		if err := Trim(anim, entry.opts); err != nil {
+			t.Errorf("%+v: %v", entry.opts, err)
+			continue
+		}
+		if actual := frameDelays(anim); !reflect.DeepEqual(actual, entry.expected) {
+			t.Errorf("%+v: expected=%v, got=%v", entry.opts, entry.expected, actual)
+		}
+
+		var buf bytes.Buffer
+		if err := anim.Encode(&buf); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	anim := decodeTestGif(t, []int{10, 10})
+	if err := Trim(anim, TrimOptions{Start: time.Second}); err != ErrEmptyResult {
+		t.Errorf("expected ErrEmptyResult, got %v", err)
+	}
+}
+
+func TestSpeed(t *testing.T) {
+	for _, entry := range []struct {
+		delays   []int
+		factor   float64
+		expected []int
+	}{
+		{[]int{10, 10, 10, 10}, 2, []int{5, 5, 5, 5}},
+		{[]int{10, 10, 10, 10}, 0.5, []int{20, 20, 20, 20}},
+		{[]int{3, 3, 3, 3}, 2, []int{2, 4}},
+		{[]int{0, 4, 4, 4, 4}, 4, []int{2, 2}},
+		{[]int{1, 1, 1, 1, 1}, 1, []int{2, 3}},
+		{[]int{5, 5, 5}, 3, []int{2, 3}},
+	} {
+		anim := decodeTestGif(t, entry.delays)
+		total := anim.Duration()
+		if err := Speed(anim, entry.factor); err != nil {
+			t.Fatal(err)
+		}
+		if actual := frameDelays(anim); !reflect.DeepEqual(actual, entry.expected) {
+			t.Errorf("%v x%v: expected=%v, got=%v", entry.delays, entry.factor, entry.expected, actual)
+		}
+
+		expectedTotal := int(math.Round(float64(total) / entry.factor))
+		if anim.Duration() != expectedTotal {
+			t.Errorf("%v x%v: wrong total delay, expected=%v, got=%v", entry.delays, entry.factor, expectedTotal, anim.Duration())
+		}
+		for _, delay := range frameDelays(anim) {
+			if delay < MinDelay {
+				t.Errorf("%v x%v: delay below minimum: %v", entry.delays, entry.factor, delay)
+			}
+		}
+	}
+
+	if err := Speed(decodeTestGif(t, []int{10, 10}), 0); err != ErrInvalidSpeed {
+		t.Errorf("expected ErrInvalidSpeed, got %v", err)
+	}
+}
+
+func TestDecimate(t *testing.T) {
+	anim := decodeTestGif(t, []int{1, 2, 3, 4, 5, 6, 7})
+	total := anim.Duration()
+	if err := Decimate(anim, 3); err != nil {
+		t.Fatal(err)
+	}
+	if actual, expected := frameDelays(anim), []int{6, 15, 7}; !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected=%v, got=%v", expected, actual)
+	}
+	if anim.Duration() != total {
+		t.Errorf("wrong total delay, expected=%v, got=%v", total, anim.Duration())
+	}
+}
@@ -0,0 +1,135 @@
+package gifedit
+
+import (
+	"errors"
+)
+
+const (
+	sExtension       = 0x21
+	sImageDescriptor = 0x2C
+	sTrailer         = 0x3B
+
+	eGraphicControl = 0xF9
+
+	fColorTable         = 1 << 7
+	fColorTableBitsMask = 7
+)
+
+var (
+	ErrNotGif     = errors.New("gifedit: not a gif file")
+	ErrNoFrames   = errors.New("gifedit: no complete frames")
+	ErrNotDamaged = errors.New("gifedit: file already has a trailer")
+)
+
+type RepairInfo struct {
+	// Number of complete frames kept.
+	NumFrames int
+
+	// Number of trailing bytes that were dropped.
+	NumDropped int
+}
+
+// Repair fixes a gif that was cut off while being written, for instance
+// when the process was killed during a recording. The incomplete last
+// frame is dropped, and a trailer is appended after the last complete one.
+// Returns ErrNotDamaged, along with the data up to the trailer,
+// if the gif is already complete.
+func Repair(data []byte) ([]byte, RepairInfo, error) {
+	var info RepairInfo
+
+	// header and logical screen descriptor
+	pos := 13
+	if len(data) < pos || string(data[:3]) != "GIF" {
+		return nil, info, ErrNotGif
+	}
+	if fields := data[10]; fields&fColorTable != 0 {
+		pos += colorTableSize(fields)
+	}
+	if len(data) < pos {
+		return nil, info, ErrNoFrames
+	}
+
+	lastGood := pos
+	pendingControl := false
+
+	for pos < len(data) {
+		switch data[pos] {
+		case sTrailer:
+			if info.NumFrames == 0 {
+				return nil, info, ErrNoFrames
+			}
+			return data[:pos+1], info, ErrNotDamaged
+
+		case sExtension:
+			if pos+1 >= len(data) {
+				return finishRepair(data, lastGood, info)
+			}
+			label := data[pos+1]
+			end, ok := skipSubBlocks(data, pos+2)
+			if !ok {
+				return finishRepair(data, lastGood, info)
+			}
+			pos = end
+			if label == eGraphicControl {
+				pendingControl = true
+			} else if !pendingControl {
+				lastGood = pos
+			}
+
+		case sImageDescriptor:
+			end := pos + 10
+			if end > len(data) {
+				return finishRepair(data, lastGood, info)
+			}
+			if fields := data[pos+9]; fields&fColorTable != 0 {
+				end += colorTableSize(fields)
+			}
+			// LZW minimum code size
+			end++
+			end, ok := skipSubBlocks(data, end)
+			if !ok {
+				return finishRepair(data, lastGood, info)
+			}
+			pos = end
+			lastGood = pos
+			pendingControl = false
+			info.NumFrames++
+
+		default:
+			// garbage, possibly from a partially flushed buffer
+			return finishRepair(data, lastGood, info)
+		}
+	}
+
+	return finishRepair(data, lastGood, info)
+}
+
+func finishRepair(data []byte, lastGood int, info RepairInfo) ([]byte, RepairInfo, error) {
+	if info.NumFrames == 0 {
+		return nil, info, ErrNoFrames
+	}
+	info.NumDropped = len(data) - lastGood
+
+	result := make([]byte, lastGood+1)
+	copy(result, data[:lastGood])
+	result[lastGood] = sTrailer
+
+	return result, info, nil
+}
+
+// skipSubBlocks returns the position after the data sub-blocks
+// starting at pos, or false if they are cut off.
+func skipSubBlocks(data []byte, pos int) (int, bool) {
+	for pos < len(data) {
+		size := int(data[pos])
+		pos += size + 1
+		if size == 0 {
+			return pos, true
+		}
+	}
+	return 0, false
+}
+
+func colorTableSize(fields byte) int {
+	return 3 * (1 << (1 + int(fields&fColorTableBitsMask)))
+}
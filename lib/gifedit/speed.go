@@ -0,0 +1,98 @@
+package gifedit
+
+import (
+	"errors"
+	"math"
+)
+
+// MinDelay is the shortest delay, in 100ths of a second, that
+// viewers respect. Most of them slow down anything shorter.
+const MinDelay = 2
+
+var ErrInvalidSpeed = errors.New("gifedit: speed must be greater than zero")
+
+// Speed changes the playback speed of the animation, a factor
+// of 2 plays twice as fast. Frames that would end up shorter than
+// MinDelay are merged into the next frame, so the total duration
+// stays close to the original duration divided by factor.
+func Speed(anim *Animation, factor float64) error {
+	if factor <= 0 || math.IsInf(factor, 0) || math.IsNaN(factor) {
+		return ErrInvalidSpeed
+	}
+
+	// scale the cumulative time instead of each delay
+	// so that rounding errors don't add up
+	elapsed := 0
+	scaledElapsed := 0
+	delays := make([]int, len(anim.Frames))
+	for i, frame := range anim.Frames {
+		elapsed += frame.Delay
+		next := int(math.Round(float64(elapsed) / factor))
+		delays[i] = next - scaledElapsed
+		scaledElapsed = next
+	}
+
+	anim.Frames = anim.mergeShortFrames(delays)
+	return nil
+}
+
+// Decimate keeps only every nth frame. The delays of the
+// dropped frames are added to the frame before them,
+// so the total duration stays the same.
+func Decimate(anim *Animation, n int) error {
+	if n < 1 {
+		return errors.New("gifedit: decimation must be at least 1")
+	}
+	if n == 1 {
+		return nil
+	}
+
+	var frames []Frame
+	for i := 0; i < len(anim.Frames); i += n {
+		frame := anim.Frames[i]
+		if i > 0 {
+			frame = anim.coalesce(i)
+		}
+		for j := i + 1; j < i+n && j < len(anim.Frames); j++ {
+			frame.Delay += anim.Frames[j].Delay
+		}
+		frames = append(frames, frame)
+	}
+
+	anim.Frames = frames
+	return nil
+}
+
+// mergeShortFrames sets the delays of the frames, and drops the frames
+// shorter than MinDelay. A dropped frame gives its delay to the next
+// kept frame, or if there is none, its image to the previous one.
+func (anim *Animation) mergeShortFrames(delays []int) []Frame {
+	var frames []Frame
+	carry := 0
+	dropped := false
+	for i := range anim.Frames {
+		delay := delays[i] + carry
+
+		if delay < MinDelay && i < len(anim.Frames)-1 {
+			carry = delay
+			dropped = true
+			continue
+		}
+		if delay < MinDelay && len(frames) > 0 {
+			prev := &frames[len(frames)-1]
+			prev.Image = anim.coalesce(i).Image
+			prev.Delay += delay
+			break
+		}
+
+		frame := anim.Frames[i]
+		if dropped {
+			frame = anim.coalesce(i)
+		}
+		frame.Delay = delay
+		frames = append(frames, frame)
+		carry = 0
+		dropped = false
+	}
+	return frames
+}
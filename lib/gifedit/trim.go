@@ -0,0 +1,77 @@
+package gifedit
+
+import (
+	"errors"
+	"time"
+)
+
+var ErrEmptyResult = errors.New("gifedit: no frames left")
+
+type TrimOptions struct {
+	// Drop this much from the start and the end of the animation.
+	Start time.Duration
+	End   time.Duration
+
+	// Keep only the frames From up to, but not including, To.
+	// A To of 0 means up to the last frame.
+	From int
+	To   int
+}
+
+// Trim drops frames from the start and the end of the animation.
+// The frame range is applied first, then the durations. Frames that
+// are only partly cut by a duration are kept with a shortened delay.
+func Trim(anim *Animation, opts TrimOptions) error {
+	from, to := opts.From, opts.To
+	if to <= 0 || to > len(anim.Frames) {
+		to = len(anim.Frames)
+	}
+	if from < 0 {
+		from = 0
+	}
+	if from >= to {
+		return ErrEmptyResult
+	}
+	if from > 0 {
+		anim.Frames[from] = anim.coalesce(from)
+	}
+	anim.Frames = anim.Frames[from:to]
+
+	start := toCentiseconds(opts.Start)
+	end := anim.Duration() - toCentiseconds(opts.End)
+	if start >= end {
+		return ErrEmptyResult
+	}
+
+	var frames []Frame
+	elapsed := 0
+	for i, frame := range anim.Frames {
+		frameStart, frameEnd := elapsed, elapsed+frame.Delay
+		elapsed = frameEnd
+
+		if (start > 0 && frameEnd <= start) || (opts.End > 0 && frameStart >= end) {
+			continue
+		}
+		if len(frames) == 0 && i > 0 {
+			frame = anim.coalesce(i)
+		}
+
+		if frameStart < start {
+			frame.Delay -= start - frameStart
+		}
+		if frameEnd > end {
+			frame.Delay -= frameEnd - end
+		}
+		frames = append(frames, frame)
+	}
+
+	if len(frames) == 0 {
+		return ErrEmptyResult
+	}
+	anim.Frames = frames
+	return nil
+}
+
+func toCentiseconds(d time.Duration) int {
+	return int(d / (10 * time.Millisecond))
+}
@@ -0,0 +1,127 @@
+// Package globalkeys reads the keyboard of the whole desktop, not just
+// of the window with the focus, so that keys typed into the app under
+// the cage can be shown in the recording. The state of the keyboard is
+// polled, on X11 and on Windows.
+package globalkeys
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+var ErrNotSupported = errors.New("reading the keyboard is only supported on X11 and Windows")
+
+// often enough to catch a quick tap
+const pollInterval = 5 * time.Millisecond
+
+// Press is a key that was pressed,
+// with the keys that were held at the time.
+type Press struct {
+	Key  ebiten.Key
+	Held []ebiten.Key
+}
+
+// poller returns the keys that are pressed right now.
+type poller interface {
+	pressed(buf []ebiten.Key) ([]ebiten.Key, error)
+	close()
+}
+
+// Source collects the key presses in the background.
+type Source struct {
+	poller poller
+
+	mu      sync.Mutex
+	presses []Press
+	err     error
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func Open() (*Source, error) {
+	p, err := openPoller()
+	if err != nil {
+		return nil, err
+	}
+	src := &Source{
+		poller: p,
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	go src.run()
+	return src, nil
+}
+
+func (src *Source) run() {
+	defer close(src.done)
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	var prev, cur []ebiten.Key
+	for {
+		select {
+		case <-src.stop:
+			return
+		case <-ticker.C:
+		}
+
+		var err error
+		cur, err = src.poller.pressed(cur[:0])
+		if err != nil {
+			log.Println("failed to read the keyboard:", err)
+			src.mu.Lock()
+			src.err = err
+			src.mu.Unlock()
+			return
+		}
+
+		if presses := Diff(prev, cur); len(presses) > 0 {
+			src.mu.Lock()
+			src.presses = append(src.presses, presses...)
+			src.mu.Unlock()
+		}
+		prev, cur = cur, prev
+	}
+}
+
+// Presses returns the keys pressed since the last call, or
+// the error that stopped the source from reading the keyboard.
+func (src *Source) Presses() ([]Press, error) {
+	src.mu.Lock()
+	defer src.mu.Unlock()
+	presses := src.presses
+	src.presses = nil
+	return presses, src.err
+}
+
+func (src *Source) Close() {
+	close(src.stop)
+	<-src.done
+	src.poller.close()
+}
+
+// Diff returns the keys in cur that are not in prev.
+func Diff(prev, cur []ebiten.Key) []Press {
+	var presses []Press
+	for _, key := range cur {
+		if !contains(prev, key) {
+			held := append([]ebiten.Key(nil), cur...)
+			presses = append(presses, Press{Key: key, Held: held})
+		}
+	}
+	return presses
+}
+
+func contains(keys []ebiten.Key, key ebiten.Key) bool {
+	for _, k := range keys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,119 @@
+package globalkeys
+
+import (
+	"fmt"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/jezek/xgb"
+	"github.com/jezek/xgb/xproto"
+)
+
+// keysyms from X11/keysymdef.h
+var keysymKeys = map[xproto.Keysym]ebiten.Key{
+	0x0020: ebiten.KeySpace,
+	0x0027: ebiten.KeyQuote,
+	0x002c: ebiten.KeyComma,
+	0x002d: ebiten.KeyMinus,
+	0x002e: ebiten.KeyPeriod,
+	0x002f: ebiten.KeySlash,
+	0x003b: ebiten.KeySemicolon,
+	0x003d: ebiten.KeyEqual,
+	0x005b: ebiten.KeyBracketLeft,
+	0x005c: ebiten.KeyBackslash,
+	0x005d: ebiten.KeyBracketRight,
+	0x0060: ebiten.KeyBackquote,
+	0xff08: ebiten.KeyBackspace,
+	0xff09: ebiten.KeyTab,
+	0xff0d: ebiten.KeyEnter,
+	0xff13: ebiten.KeyPause,
+	0xff14: ebiten.KeyScrollLock,
+	0xff1b: ebiten.KeyEscape,
+	0xff50: ebiten.KeyHome,
+	0xff51: ebiten.KeyArrowLeft,
+	0xff52: ebiten.KeyArrowUp,
+	0xff53: ebiten.KeyArrowRight,
+	0xff54: ebiten.KeyArrowDown,
+	0xff55: ebiten.KeyPageUp,
+	0xff56: ebiten.KeyPageDown,
+	0xff57: ebiten.KeyEnd,
+	0xff61: ebiten.KeyPrintScreen,
+	0xff63: ebiten.KeyInsert,
+	0xff67: ebiten.KeyContextMenu,
+	0xff7f: ebiten.KeyNumLock,
+	0xffe1: ebiten.KeyShiftLeft,
+	0xffe2: ebiten.KeyShiftRight,
+	0xffe3: ebiten.KeyControlLeft,
+	0xffe4: ebiten.KeyControlRight,
+	0xffe5: ebiten.KeyCapsLock,
+	0xffe7: ebiten.KeyMetaLeft,
+	0xffe8: ebiten.KeyMetaRight,
+	0xffe9: ebiten.KeyAltLeft,
+	0xffea: ebiten.KeyAltRight,
+	0xffeb: ebiten.KeyMetaLeft,
+	0xffec: ebiten.KeyMetaRight,
+	0xffff: ebiten.KeyDelete,
+}
+
+func init() {
+	for i := xproto.Keysym(0); i < 26; i++ {
+		keysymKeys[0x61+i] = ebiten.KeyA + ebiten.Key(i)
+	}
+	for i := xproto.Keysym(0); i < 10; i++ {
+		keysymKeys[0x30+i] = ebiten.KeyDigit0 + ebiten.Key(i)
+	}
+	for i := xproto.Keysym(0); i < 12; i++ {
+		keysymKeys[0xffbe+i] = ebiten.KeyF1 + ebiten.Key(i)
+	}
+}
+
+type keymapPoller struct {
+	x *xgb.Conn
+
+	// by keycode, for the keycodes of known keys
+	keys map[byte]ebiten.Key
+}
+
+func openPoller() (poller, error) {
+	x, err := xgb.NewConn()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrNotSupported, err)
+	}
+
+	setup := xproto.Setup(x)
+	count := byte(setup.MaxKeycode-setup.MinKeycode) + 1
+	mapping, err := xproto.GetKeyboardMapping(x, setup.MinKeycode, count).Reply()
+	if err != nil {
+		x.Close()
+		return nil, err
+	}
+
+	p := &keymapPoller{x: x, keys: map[byte]ebiten.Key{}}
+	perKeycode := int(mapping.KeysymsPerKeycode)
+	for i := 0; i < int(count); i++ {
+		if perKeycode == 0 || i*perKeycode >= len(mapping.Keysyms) {
+			break
+		}
+		// the first keysym is the one without shift
+		if key, ok := keysymKeys[mapping.Keysyms[i*perKeycode]]; ok {
+			p.keys[byte(setup.MinKeycode)+byte(i)] = key
+		}
+	}
+	return p, nil
+}
+
+func (p *keymapPoller) pressed(buf []ebiten.Key) ([]ebiten.Key, error) {
+	reply, err := xproto.QueryKeymap(p.x).Reply()
+	if err != nil {
+		return buf, err
+	}
+	for keycode, key := range p.keys {
+		if reply.Keys[keycode/8]&(1<<(keycode%8)) != 0 {
+			buf = append(buf, key)
+		}
+	}
+	return buf, nil
+}
+
+func (p *keymapPoller) close() {
+	p.x.Close()
+}
@@ -0,0 +1,7 @@
+//go:build !linux && !windows
+
+package globalkeys
+
+func openPoller() (poller, error) {
+	return nil, ErrNotSupported
+}
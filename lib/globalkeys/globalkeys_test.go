@@ -0,0 +1,33 @@
+package globalkeys
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+func TestDiff(t *testing.T) {
+	prev := []ebiten.Key{ebiten.KeyControlLeft}
+	cur := []ebiten.Key{ebiten.KeyControlLeft, ebiten.KeyC}
+	want := []Press{{Key: ebiten.KeyC, Held: cur}}
+	if got := Diff(prev, cur); !reflect.DeepEqual(got, want) {
+		t.Errorf("Diff(%v, %v) = %v, want %v", prev, cur, got, want)
+	}
+
+	if got := Diff(cur, cur); len(got) != 0 {
+		t.Errorf("held keys are pressed again: %v", got)
+	}
+	if got := Diff(cur, prev); len(got) != 0 {
+		t.Errorf("released keys are pressed: %v", got)
+	}
+
+	presses := Diff(nil, cur)
+	if len(presses) != 2 {
+		t.Fatalf("Diff(nil, %v) = %v, want 2 presses", cur, presses)
+	}
+	presses[0].Held[0] = ebiten.KeyA
+	if cur[0] != ebiten.KeyControlLeft {
+		t.Errorf("Held shares the slice it was made from")
+	}
+}
@@ -0,0 +1,88 @@
+package globalkeys
+
+import (
+	"syscall"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+var procGetAsyncKeyState = syscall.NewLazyDLL("user32.dll").NewProc("GetAsyncKeyState")
+
+// virtual-key codes
+var vkKeys = map[uintptr]ebiten.Key{
+	0x08: ebiten.KeyBackspace,
+	0x09: ebiten.KeyTab,
+	0x0d: ebiten.KeyEnter,
+	0x13: ebiten.KeyPause,
+	0x14: ebiten.KeyCapsLock,
+	0x1b: ebiten.KeyEscape,
+	0x20: ebiten.KeySpace,
+	0x21: ebiten.KeyPageUp,
+	0x22: ebiten.KeyPageDown,
+	0x23: ebiten.KeyEnd,
+	0x24: ebiten.KeyHome,
+	0x25: ebiten.KeyArrowLeft,
+	0x26: ebiten.KeyArrowUp,
+	0x27: ebiten.KeyArrowRight,
+	0x28: ebiten.KeyArrowDown,
+	0x2c: ebiten.KeyPrintScreen,
+	0x2d: ebiten.KeyInsert,
+	0x2e: ebiten.KeyDelete,
+	0x5b: ebiten.KeyMetaLeft,
+	0x5c: ebiten.KeyMetaRight,
+	0x5d: ebiten.KeyContextMenu,
+	0x90: ebiten.KeyNumLock,
+	0x91: ebiten.KeyScrollLock,
+	0xa0: ebiten.KeyShiftLeft,
+	0xa1: ebiten.KeyShiftRight,
+	0xa2: ebiten.KeyControlLeft,
+	0xa3: ebiten.KeyControlRight,
+	0xa4: ebiten.KeyAltLeft,
+	0xa5: ebiten.KeyAltRight,
+	0xba: ebiten.KeySemicolon,
+	0xbb: ebiten.KeyEqual,
+	0xbc: ebiten.KeyComma,
+	0xbd: ebiten.KeyMinus,
+	0xbe: ebiten.KeyPeriod,
+	0xbf: ebiten.KeySlash,
+	0xc0: ebiten.KeyBackquote,
+	0xdb: ebiten.KeyBracketLeft,
+	0xdc: ebiten.KeyBackslash,
+	0xdd: ebiten.KeyBracketRight,
+	0xde: ebiten.KeyQuote,
+}
+
+func init() {
+	for i := uintptr(0); i < 26; i++ {
+		vkKeys[0x41+i] = ebiten.KeyA + ebiten.Key(i)
+	}
+	for i := uintptr(0); i < 10; i++ {
+		vkKeys[0x30+i] = ebiten.KeyDigit0 + ebiten.Key(i)
+	}
+	for i := uintptr(0); i < 12; i++ {
+		vkKeys[0x70+i] = ebiten.KeyF1 + ebiten.Key(i)
+	}
+}
+
+type asyncPoller struct{}
+
+func openPoller() (poller, error) {
+	if err := procGetAsyncKeyState.Find(); err != nil {
+		return nil, err
+	}
+	return asyncPoller{}, nil
+}
+
+func (asyncPoller) pressed(buf []ebiten.Key) ([]ebiten.Key, error) {
+	for vk, key := range vkKeys {
+		state, _, _ := procGetAsyncKeyState.Call(vk)
+		// the high bit is down, the low bit is pressed since the
+		// last call, which catches taps between two polls
+		if state&0x8001 != 0 {
+			buf = append(buf, key)
+		}
+	}
+	return buf, nil
+}
+
+func (asyncPoller) close() {}
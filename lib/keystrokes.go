@@ -0,0 +1,186 @@
+package lib
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/nvlled/screencage/lib/globalkeys"
+)
+
+type KeystrokeSettings struct {
+	// Show the pressed keys at the bottom of the frames.
+	Show bool `json:"show"`
+
+	// Seconds a key stays visible, including the fade out.
+	Duration float64 `json:"duration"`
+	FadeOut  float64 `json:"fadeOut"`
+
+	// Keys pressed while this key is held are not shown,
+	// for instance "ScrollLock". Empty means disabled.
+	PasswordKey string `json:"passwordKey"`
+}
+
+var defaultKeystrokes = KeystrokeSettings{
+	Duration: 2,
+	FadeOut:  0.5,
+}
+
+const maxKeystrokes = 8
+
+type Keystroke struct {
+	Label string
+	Time  time.Time
+}
+
+// KeystrokeLog keeps the recently pressed keys. The keys are read
+// from the whole desktop, since while recording, the keys are
+// typed into the app under the cage.
+type KeystrokeLog struct {
+	keys []Keystroke
+}
+
+// Update records the presses, with the held modifiers,
+// like Ctrl+C. Must be called on every tick.
+func (kl *KeystrokeLog) Update(s KeystrokeSettings, presses []globalkeys.Press, now time.Time) {
+	maxAge := time.Duration(s.Duration * float64(time.Second))
+	for len(kl.keys) > 0 && now.Sub(kl.keys[0].Time) > maxAge {
+		kl.keys = kl.keys[1:]
+	}
+
+	if !s.Show {
+		return
+	}
+
+	var passwordKey ebiten.Key
+	hasPasswordKey := s.PasswordKey != "" && passwordKey.UnmarshalText([]byte(s.PasswordKey)) == nil
+
+	for _, press := range presses {
+		if modifierName(press.Key) != "" {
+			continue
+		}
+		if hasPasswordKey && (press.Key == passwordKey || isHeld(press, passwordKey)) {
+			continue
+		}
+		var modifiers []string
+		for _, key := range press.Held {
+			if name := modifierName(key); name != "" {
+				modifiers = append(modifiers, name)
+			}
+		}
+		label := strings.Join(append(modifiers, press.Key.String()), "+")
+		kl.keys = append(kl.keys, Keystroke{Label: label, Time: now})
+	}
+
+	if len(kl.keys) > maxKeystrokes {
+		kl.keys = kl.keys[len(kl.keys)-maxKeystrokes:]
+	}
+}
+
+// Caption returns the recent keys and how visible they are,
+// from 1 down to 0 while fading out.
+func (kl *KeystrokeLog) Caption(s KeystrokeSettings, now time.Time) (string, float64) {
+	if len(kl.keys) == 0 {
+		return "", 0
+	}
+
+	var labels []string
+	for _, key := range kl.keys {
+		labels = append(labels, key.Label)
+	}
+
+	age := now.Sub(kl.keys[len(kl.keys)-1].Time).Seconds()
+	fadeStart := s.Duration - s.FadeOut
+	alpha := 1.0
+	if age > fadeStart && s.FadeOut > 0 {
+		alpha = 1 - (age-fadeStart)/s.FadeOut
+	}
+	if alpha <= 0 {
+		return "", 0
+	}
+
+	return strings.Join(labels, " "), alpha
+}
+
+func isHeld(press globalkeys.Press, key ebiten.Key) bool {
+	for _, held := range press.Held {
+		if held == key {
+			return true
+		}
+	}
+	return false
+}
+
+func modifierName(key ebiten.Key) string {
+	switch key {
+	case ebiten.KeyControlLeft, ebiten.KeyControlRight:
+		return "Ctrl"
+	case ebiten.KeyAltLeft, ebiten.KeyAltRight:
+		return "Alt"
+	case ebiten.KeyShiftLeft, ebiten.KeyShiftRight:
+		return "Shift"
+	case ebiten.KeyMetaLeft, ebiten.KeyMetaRight:
+		return "Meta"
+	}
+	return ""
+}
+
+// updateKeystrokes reads the keyboard while the keys are shown.
+// Where it can't be read, showing the keys is turned off again.
+func (g *App) updateKeystrokes() {
+	s := &g.settings.Keystrokes
+	if s.Show && g.keySource == nil {
+		g.keySource, g.keySourceErr = globalkeys.Open()
+		if g.keySourceErr != nil {
+			log.Println("can't show keys:", g.keySourceErr)
+			s.Show = false
+		}
+	}
+	if !s.Show && g.keySource != nil {
+		g.keySource.Close()
+		g.keySource = nil
+	}
+
+	var presses []globalkeys.Press
+	if g.keySource != nil {
+		var err error
+		presses, err = g.keySource.Presses()
+		if err != nil {
+			g.keySourceErr = err
+			g.keySource.Close()
+			g.keySource = nil
+			s.Show = false
+		}
+	}
+	g.keystrokes.Update(*s, presses, time.Now())
+}
+
+// keystrokesInfo describes the key overlay for the info overlay.
+func (g *App) keystrokesInfo() string {
+	if !g.settings.Keystrokes.Show && g.keySourceErr != nil {
+		return fmt.Sprintf("unavailable, %v", g.keySourceErr)
+	}
+	return fmt.Sprint(g.settings.Keystrokes.Show)
+}
+
+// drawKeystrokes prints the recently pressed keys
+// at the bottom center of a captured frame.
+func (g *App) drawKeystrokes(img *image.RGBA) {
+	s := g.settings.Keystrokes
+	caption, alpha := g.keystrokes.Caption(s, time.Now())
+	if caption == "" {
+		return
+	}
+
+	a := uint8(alpha * 255)
+	scrp := g.framePrint
+	scrp.ResetFrame(img)
+	scrp.Font = g.regularFont
+	scrp.Color = color.NRGBA{255, 255, 255, a}
+	scrp.Background = color.NRGBA{0, 0, 0, uint8(alpha * 180)}
+	scrp.PrintAt(0b1101, caption)
+}
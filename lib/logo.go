@@ -0,0 +1,182 @@
+package lib
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/png"
+	"os"
+
+	"github.com/ericpauley/go-quantize/quantize"
+	xdraw "golang.org/x/image/draw"
+)
+
+type LogoSettings struct {
+	// PNG image drawn on every frame, disabled if empty.
+	Path string `json:"path"`
+
+	Corner Corner `json:"corner"`
+
+	// 1 keeps the size of the image.
+	Scale float64 `json:"scale"`
+
+	// From 0, invisible, to 1, opaque. Defaults to 1 when
+	// left out of the settings file.
+	Opacity float64 `json:"opacity"`
+}
+
+var defaultLogo = LogoSettings{
+	Corner:  CornerBottomRight,
+	Scale:   1,
+	Opacity: 1,
+}
+
+// number of palette colors reserved for the logo in gif frames
+const logoPaletteSize = 32
+
+const logoMargin = 10
+
+type Logo struct {
+	settings LogoSettings
+
+	Image *image.RGBA
+
+	// the main opaque colors of the logo, reserved
+	// when quantizing frames so that it doesn't band
+	Palette color.Palette
+
+	Err error
+}
+
+func LoadLogo(s LogoSettings) *Logo {
+	logo := &Logo{settings: s}
+
+	file, err := os.Open(s.Path)
+	if err != nil {
+		logo.Err = err
+		return logo
+	}
+	defer file.Close()
+
+	src, _, err := image.Decode(file)
+	if err != nil {
+		logo.Err = err
+		return logo
+	}
+
+	b := src.Bounds()
+	scale := s.Scale
+	if scale <= 0 {
+		scale = 1
+	}
+	w := int(float64(b.Dx()) * scale)
+	h := int(float64(b.Dy()) * scale)
+	if w < 1 || h < 1 {
+		w, h = 1, 1
+	}
+
+	logo.Image = image.NewRGBA(image.Rect(0, 0, w, h))
+	xdraw.CatmullRom.Scale(logo.Image, logo.Image.Bounds(), src, b, xdraw.Src, nil)
+
+	logo.Palette = logoPalette(logo.Image)
+
+	return logo
+}
+
+// logoPalette quantizes the opaque pixels of img. The others are
+// blended with the frame, so their colors never show as they are.
+func logoPalette(img *image.RGBA) color.Palette {
+	opaque := false
+	for i := 3; i < len(img.Pix); i += 4 {
+		if img.Pix[i] == 255 {
+			opaque = true
+			break
+		}
+	}
+	if !opaque {
+		return nil
+	}
+
+	quantizer := quantize.MedianCutQuantizer{
+		Weighting: func(_ image.Image, x, y int) uint32 {
+			if img.RGBAAt(x, y).A == 255 {
+				return 1
+			}
+			return 0
+		},
+	}
+	return quantizer.Quantize(make(color.Palette, 0, logoPaletteSize), img)
+}
+
+// Draw composites the logo in its corner of img.
+func (logo *Logo) Draw(img *image.RGBA) {
+	if logo.Image == nil {
+		return
+	}
+
+	alpha := logoAlpha(logo.settings.Opacity)
+	if alpha == 0 {
+		return
+	}
+	lb := logo.Image.Bounds()
+	r := logoRect(img.Bounds(), lb.Size(), logo.settings.Corner)
+	mask := image.NewUniform(color.Alpha{alpha})
+	draw.DrawMask(img, r, logo.Image, lb.Min, mask, image.Point{}, draw.Over)
+}
+
+// logoRect returns where a logo of the size goes
+// in the corner of bounds, inside the margin.
+func logoRect(bounds image.Rectangle, size image.Point, corner Corner) image.Rectangle {
+	var pos image.Point
+	switch corner {
+	case CornerTopLeft:
+		pos = image.Pt(bounds.Min.X+logoMargin, bounds.Min.Y+logoMargin)
+	case CornerTopRight:
+		pos = image.Pt(bounds.Max.X-size.X-logoMargin, bounds.Min.Y+logoMargin)
+	case CornerBottomLeft:
+		pos = image.Pt(bounds.Min.X+logoMargin, bounds.Max.Y-size.Y-logoMargin)
+	default:
+		pos = image.Pt(bounds.Max.X-size.X-logoMargin, bounds.Max.Y-size.Y-logoMargin)
+	}
+	return image.Rectangle{pos, pos.Add(size)}
+}
+
+// logoAlpha returns the mask alpha for the opacity, clamped to [0, 1].
+func logoAlpha(opacity float64) uint8 {
+	if opacity <= 0 {
+		return 0
+	}
+	if opacity >= 1 {
+		return 255
+	}
+	return uint8(opacity*255 + 0.5)
+}
+
+// getLogo returns the logo from the settings,
+// reloading it whenever the settings change.
+func (g *App) getLogo() *Logo {
+	s := g.settings.Logo
+	if s.Path == "" {
+		return nil
+	}
+	if g.logo == nil || g.logo.settings != s {
+		g.logo = LoadLogo(s)
+		if g.logo.Err != nil {
+			g.logError(g.logo.Err)
+		}
+	}
+	if g.logo.Err != nil {
+		return nil
+	}
+	return g.logo
+}
+
+// reservedPalette returns the colors that are always included in the
+// palette of gif frames. A translucent logo is blended with the frame,
+// so none of its colors are reserved.
+func (g *App) reservedPalette() color.Palette {
+	if logo := g.getLogo(); logo != nil && logoAlpha(logo.settings.Opacity) == 255 {
+		return logo.Palette
+	}
+	return nil
+}
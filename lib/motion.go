@@ -0,0 +1,154 @@
+package lib
+
+import (
+	"fmt"
+	"image"
+	"time"
+)
+
+type MotionSettings struct {
+	// Only record while the capture area changes.
+	Enabled bool `json:"enabled"`
+
+	// Percentage of pixels that must change to count as motion.
+	Threshold float64 `json:"threshold"`
+
+	// How much a color channel must change, from 0 to 255,
+	// for a pixel to count as changed.
+	PixelThreshold int `json:"pixelThreshold"`
+
+	// Seconds without motion before a burst ends.
+	IdleSeconds float64 `json:"idleSeconds"`
+
+	// Areas where changes are ignored, like a clock.
+	// Relative to the capture area, in physical pixels.
+	IgnoreMasks []Rect `json:"ignoreMasks"`
+
+	Output MotionOutput `json:"output"`
+}
+
+var defaultMotion = MotionSettings{
+	Threshold:      0.5,
+	PixelThreshold: 24,
+	IdleSeconds:    3,
+}
+
+type MotionOutput int
+
+const (
+	// one gif with the time without motion left out
+	MotionCollapse MotionOutput = iota
+	// one gif for each burst of motion
+	MotionSplit
+
+	MotionOutput_Size
+)
+
+func (output MotionOutput) String() string {
+	switch output {
+	case MotionCollapse:
+		return "collapse gaps"
+	case MotionSplit:
+		return "file per burst"
+	}
+	return "invalid-motion-output"
+}
+
+func (s MotionSettings) String() string {
+	if !s.Enabled {
+		return "off"
+	}
+	return fmt.Sprintf("%v%%, %vs idle, %v", s.Threshold, s.IdleSeconds, s.Output)
+}
+
+// only every motionSampleStep-th pixel in each direction is compared
+const motionSampleStep = 2
+
+// MotionDetector tells when the capture area starts
+// and stops changing.
+type MotionDetector struct {
+	settings MotionSettings
+
+	prev       *image.RGBA
+	active     bool
+	lastMotion time.Duration
+}
+
+func NewMotionDetector(s MotionSettings) *MotionDetector {
+	return &MotionDetector{settings: s}
+}
+
+// Update compares img with the previous frame. It returns whether
+// the frame is part of a burst of motion, and whether it's the
+// first frame of one. at is the time of the frame.
+func (d *MotionDetector) Update(img *image.RGBA, at time.Duration) (record bool, start bool) {
+	moved := d.prev != nil && d.Difference(img) > d.settings.Threshold
+	d.keep(img)
+
+	idle := time.Duration(d.settings.IdleSeconds * float64(time.Second))
+	switch {
+	case moved:
+		start = !d.active
+		d.active = true
+		d.lastMotion = at
+	case d.active && at-d.lastMotion > idle:
+		d.active = false
+	}
+	return d.active, start
+}
+
+// Difference returns the percentage of pixels
+// that changed since the previous frame.
+func (d *MotionDetector) Difference(img *image.RGBA) float64 {
+	prev := d.prev
+	if prev == nil || prev.Rect.Size() != img.Rect.Size() {
+		return 100
+	}
+
+	masks := make([]image.Rectangle, len(d.settings.IgnoreMasks))
+	for i, mask := range d.settings.IgnoreMasks {
+		masks[i] = mask.Rectangle()
+	}
+
+	threshold := d.settings.PixelThreshold
+	size := img.Rect.Size()
+	total, changed := 0, 0
+	for y := 0; y < size.Y; y += motionSampleStep {
+	next:
+		for x := 0; x < size.X; x += motionSampleStep {
+			p := image.Pt(x, y)
+			for _, mask := range masks {
+				if p.In(mask) {
+					continue next
+				}
+			}
+			total++
+			i := img.PixOffset(img.Rect.Min.X+x, img.Rect.Min.Y+y)
+			j := prev.PixOffset(x, y)
+			for c := 0; c < 3; c++ {
+				diff := int(img.Pix[i+c]) - int(prev.Pix[j+c])
+				if diff > threshold || -diff > threshold {
+					changed++
+					break
+				}
+			}
+		}
+	}
+	if total == 0 {
+		return 0
+	}
+	return float64(changed) * 100 / float64(total)
+}
+
+// keep copies img, since the frame is drawn on after this.
+func (d *MotionDetector) keep(img *image.RGBA) {
+	size := img.Rect.Size()
+	if d.prev == nil || d.prev.Rect.Size() != size {
+		d.prev = image.NewRGBA(image.Rectangle{Max: size})
+	}
+	for y := 0; y < size.Y; y++ {
+		i := img.PixOffset(img.Rect.Min.X, img.Rect.Min.Y+y)
+		j := d.prev.PixOffset(0, y)
+		copy(d.prev.Pix[j:j+size.X*4], img.Pix[i:i+size.X*4])
+	}
+}
@@ -1,7 +1,10 @@
 package lib
 
 import (
+	"errors"
 	"fmt"
+	"io/fs"
+	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
@@ -83,3 +86,105 @@ func IncrementFilename(filename string) string {
 	num++
 	return fmt.Sprintf("%v-%v%v", filename, num, ext)
 }
+
+// CreateOutputFile creates the file for the next capture, creating
+// missing directories along the way. With OutputMethodNewFile, the name
+// is reserved with O_EXCL, and the counter moves on whenever the name
+// is taken by another file or another instance. With
+// OutputMethodOverwrite, the existing file is truncated.
+func CreateOutputFile(tmpl OutputTemplate, method OutputMethod) (*os.File, int, error) {
+	if method != OutputMethodNewFile {
+		filename, counter, err := OverwriteFilename(tmpl)
+		if err != nil {
+			return nil, 0, err
+		}
+		file, err := createTruncated(filename)
+		return file, counter, err
+	}
+
+	counter := 0
+	if tmpl.HasCounter() {
+		latest, err := tmpl.LatestCounter()
+		if err != nil {
+			return nil, 0, err
+		}
+		counter = latest + 1
+	}
+
+	return CreateExclusiveFile(tmpl.Filename, counter, tmpl.LatestCounter)
+}
+
+// OverwriteFilename returns the file that OutputMethodOverwrite replaces:
+// the expanded template, or the latest file for templates with {counter}.
+func OverwriteFilename(tmpl OutputTemplate) (string, int, error) {
+	counter := 0
+	if tmpl.HasCounter() {
+		var err error
+		if counter, err = tmpl.LatestCounter(); err != nil {
+			return "", 0, err
+		}
+		if counter == 0 {
+			counter = 1
+		}
+	}
+	return tmpl.Filename(counter), counter, nil
+}
+
+// CreateExclusiveFile creates the first filename from nameOf that does
+// not exist yet, starting at counter. On collision, latest is used to
+// skip past the files that already exist.
+func CreateExclusiveFile(nameOf func(int) string, counter int, latest func() (int, error)) (*os.File, int, error) {
+	const maxAttempts = 1000
+
+	var err error
+	for i := 0; i < maxAttempts; i++ {
+		filename := nameOf(counter)
+		if err = os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			return nil, 0, err
+		}
+
+		var file *os.File
+		file, err = os.OpenFile(filename, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			return file, counter, nil
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return nil, 0, err
+		}
+
+		n, latestErr := latest()
+		if latestErr != nil {
+			return nil, 0, latestErr
+		}
+		if n >= counter {
+			counter = n + 1
+		} else {
+			counter++
+		}
+	}
+
+	return nil, 0, fmt.Errorf("failed to find a free filename after %v attempts: %w", maxAttempts, err)
+}
+
+// CreateNextIncrementedFile creates the file after the latest
+// incremented file of filename, like NextLatestIncrementedFilename,
+// but reserves the name with O_EXCL.
+func CreateNextIncrementedFile(filename string) (*os.File, error) {
+	latest := func() (int, error) {
+		_, next := NextLatestIncrementedFilename(filename)
+		return next - 1, nil
+	}
+	nameOf := func(counter int) string {
+		return ReplaceIncrementedFilename(filename, counter)
+	}
+	counter, _ := latest()
+	file, _, err := CreateExclusiveFile(nameOf, counter+1, latest)
+	return file, err
+}
+
+func createTruncated(filename string) (*os.File, error) {
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return nil, err
+	}
+	return os.OpenFile(filename, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+}
@@ -0,0 +1,247 @@
+package lib
+
+import (
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// OutputTemplate expands an output filename template such as
+// "~/shots/{date}/{time}-{w}x{h}-{counter:03}.{ext}".
+//
+// Supported placeholders:
+//
+//	{date}          capture date, 2006-01-02
+//	{time}          capture time, 15-04-05
+//	{x} {y} {w} {h} capture rectangle
+//	{ext}           extension of the output type, without the dot
+//	{counter}       collision-free counter, {counter:03} pads it to 3 digits
+//
+// A filename without placeholders keeps the old name-N.ext scheme.
+// A template with placeholders but no {counter} gets a -N suffix
+// only when the expanded name is already taken.
+type OutputTemplate struct {
+	Template string
+	Time     time.Time
+	Bounds   image.Rectangle
+	Ext      string
+}
+
+func NewOutputTemplate(template string, outputType OutputType, bounds image.Rectangle) OutputTemplate {
+	return OutputTemplate{
+		Template: template,
+		Time:     time.Now(),
+		Bounds:   bounds,
+		Ext:      outputType.String(),
+	}
+}
+
+// HasCounter reports whether the template has an explicit {counter}.
+func (t OutputTemplate) HasCounter() bool {
+	return hasPlaceholder(t.Template, "counter")
+}
+
+// hasPlaceholder reports whether the template has the placeholder,
+// with or without an argument.
+func hasPlaceholder(template, name string) bool {
+	found := false
+	expandPlaceholders(template, func(placeholder, _ string) (string, bool) {
+		if placeholder == name {
+			found = true
+		}
+		return "", false
+	})
+	return found
+}
+
+func (t OutputTemplate) isLiteral() bool {
+	literal := true
+	expandPlaceholders(t.Template, func(name, arg string) (string, bool) {
+		if _, ok := t.lookup(name, arg); ok || name == "counter" {
+			literal = false
+		}
+		return "", false
+	})
+	return literal
+}
+
+// Filename returns the expanded filename for the given counter.
+// For templates without {counter}, a counter of 0 means no suffix.
+func (t OutputTemplate) Filename(counter int) string {
+	if t.HasCounter() {
+		prefix, suffix, width := t.split()
+		return prefix + formatCounter(counter, width) + suffix
+	}
+
+	filename := t.expand()
+	if counter == 0 {
+		return filename
+	}
+	if t.isLiteral() {
+		return ReplaceIncrementedFilename(filename, counter)
+	}
+	base, ext := TrimExt(filename)
+	return fmt.Sprintf("%v-%v%v", base, counter, ext)
+}
+
+// LatestCounter returns the highest counter among the existing
+// files that match the template, or 0 if there are none. The
+// {counter} can also be in a directory, like run-{counter}/shot.png.
+func (t OutputTemplate) LatestCounter() (int, error) {
+	if !t.HasCounter() && t.isLiteral() {
+		_, next := NextLatestIncrementedFilename(t.expand())
+		return next - 1, nil
+	}
+
+	var prefix, suffix string
+	if t.HasCounter() {
+		prefix, suffix, _ = t.split()
+	} else {
+		base, ext := TrimExt(t.expand())
+		prefix, suffix = base+"-", ext
+	}
+
+	files, err := filepath.Glob(escapeGlob(prefix) + "*" + escapeGlob(suffix))
+	if err != nil {
+		return 0, err
+	}
+
+	// Glob returns cleaned paths, so the prefix and suffix are
+	// cleaned the same way before they are cut off
+	prefix, suffix, _ = strings.Cut(filepath.Clean(prefix+"\x00"+suffix), "\x00")
+	maxNum := 0
+	for _, file := range files {
+		if len(file) < len(prefix)+len(suffix) || !strings.HasPrefix(file, prefix) || !strings.HasSuffix(file, suffix) {
+			continue
+		}
+		digits := file[len(prefix) : len(file)-len(suffix)]
+		if !isDigits(digits) {
+			continue
+		}
+		if n, err := strconv.Atoi(digits); err == nil && n > maxNum {
+			maxNum = n
+		}
+	}
+
+	return maxNum, nil
+}
+
+func (t OutputTemplate) expand() string {
+	return expandHome(expandPlaceholders(t.Template, t.lookup))
+}
+
+// split expands the template around the first {counter}.
+func (t OutputTemplate) split() (prefix, suffix string, width int) {
+	found := false
+	expanded := expandPlaceholders(t.Template, func(name, arg string) (string, bool) {
+		if name == "counter" {
+			if !found {
+				found = true
+				width, _ = strconv.Atoi(arg)
+				return "\x00", true
+			}
+			return "", true
+		}
+		return t.lookup(name, arg)
+	})
+
+	i := strings.IndexByte(expanded, 0)
+	return expandHome(expanded[:i]), expanded[i+1:], width
+}
+
+func (t OutputTemplate) lookup(name, arg string) (string, bool) {
+	switch name {
+	case "date":
+		return t.Time.Format("2006-01-02"), true
+	case "time":
+		return t.Time.Format("15-04-05"), true
+	case "x":
+		return strconv.Itoa(t.Bounds.Min.X), true
+	case "y":
+		return strconv.Itoa(t.Bounds.Min.Y), true
+	case "w":
+		return strconv.Itoa(t.Bounds.Dx()), true
+	case "h":
+		return strconv.Itoa(t.Bounds.Dy()), true
+	case "ext":
+		return t.Ext, true
+	}
+	return "", false
+}
+
+// expandPlaceholders replaces every {name} or {name:arg} in str
+// with the value returned by lookup. Unknown placeholders are kept as is.
+func expandPlaceholders(str string, lookup func(name, arg string) (string, bool)) string {
+	var sb strings.Builder
+	for {
+		start := strings.IndexByte(str, '{')
+		if start < 0 {
+			break
+		}
+		end := strings.IndexByte(str[start:], '}')
+		if end < 0 {
+			break
+		}
+		end += start
+
+		name, arg, _ := strings.Cut(str[start+1:end], ":")
+		sb.WriteString(str[:start])
+		if value, ok := lookup(name, arg); ok {
+			sb.WriteString(value)
+		} else {
+			sb.WriteString(str[start : end+1])
+		}
+		str = str[end+1:]
+	}
+	sb.WriteString(str)
+	return sb.String()
+}
+
+func expandHome(filename string) string {
+	if filename != "~" && !strings.HasPrefix(filename, "~/") && !strings.HasPrefix(filename, `~\`) {
+		return filename
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filename
+	}
+	return filepath.Join(home, filename[1:])
+}
+
+func formatCounter(counter, width int) string {
+	return fmt.Sprintf("%0*d", width, counter)
+}
+
+func escapeGlob(pattern string) string {
+	var sb strings.Builder
+	for _, ch := range pattern {
+		switch {
+		case ch == '*' || ch == '?' || ch == '[':
+			sb.WriteByte('[')
+			sb.WriteRune(ch)
+			sb.WriteByte(']')
+		case ch == '\\' && runtime.GOOS != "windows":
+			sb.WriteString(`\\`)
+		default:
+			sb.WriteRune(ch)
+		}
+	}
+	return sb.String()
+}
+
+func isDigits(str string) bool {
+	if str == "" {
+		return false
+	}
+	for _, ch := range str {
+		if ch < '0' || ch > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,92 @@
+package lib
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+const pendingFileSuffix = ".tmp"
+
+// PendingFile is a temporary file that sits next to the output file
+// while a recording is in progress. The output file is only replaced
+// once the recording is committed, so a crash or an error never
+// clobbers a previous good capture.
+type PendingFile struct {
+	*os.File
+
+	// Target is where the file is moved on Commit.
+	Target string
+
+	// reserved is true when Target is an empty placeholder
+	// created with CreateOutputFile, which must be removed
+	// if the recording is not committed.
+	reserved bool
+}
+
+func CreatePendingFile(target string, reserved bool) (*PendingFile, error) {
+	dir, name := filepath.Split(target)
+	if dir == "" {
+		dir = "."
+	}
+	file, err := os.CreateTemp(dir, name+".*"+pendingFileSuffix)
+	if err != nil {
+		return nil, err
+	}
+	return &PendingFile{File: file, Target: target, reserved: reserved}, nil
+}
+
+// Commit closes the file and renames it to the target. The file
+// gets the mode of a normally created file, os.CreateTemp makes
+// it readable by the owner only.
+func (pf *PendingFile) Commit() error {
+	if err := pf.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(pf.Name(), fs.FileMode(0644&^umask)); err != nil {
+		return err
+	}
+	return os.Rename(pf.Name(), pf.Target)
+}
+
+// Discard closes and removes the file, leaving the target as it was.
+func (pf *PendingFile) Discard() error {
+	pf.Close()
+	pf.removeReserved()
+	return os.Remove(pf.Name())
+}
+
+// Salvage closes the file and renames it to a *.partial file
+// next to the target, returning the new filename.
+func (pf *PendingFile) Salvage() (string, error) {
+	pf.Close()
+	pf.removeReserved()
+
+	filename := PartialFilename(pf.Target)
+	if err := os.Rename(pf.Name(), filename); err != nil {
+		return "", err
+	}
+	return filename, nil
+}
+
+func (pf *PendingFile) removeReserved() {
+	if !pf.reserved {
+		return
+	}
+	if info, err := os.Stat(pf.Target); err == nil && info.Size() == 0 {
+		os.Remove(pf.Target)
+	}
+}
+
+// PartialFilename returns a free filename for salvaged recordings,
+// for example capture.gif becomes capture.partial.gif.
+func PartialFilename(target string) string {
+	base, ext := TrimExt(target)
+	filename := base + ".partial" + ext
+	if _, err := os.Stat(filename); errors.Is(err, fs.ErrNotExist) {
+		return filename
+	}
+	filename, _ = NextLatestIncrementedFilename(filename)
+	return filename
+}
@@ -1,6 +1,7 @@
 package lib
 
 import (
+	"errors"
 	"image"
 	"image/color"
 	"image/draw"
@@ -13,9 +14,9 @@ import (
 	"github.com/ericpauley/go-quantize/quantize"
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/hajimehoshi/ebiten/v2/inpututil"
-	"github.com/kbinani/screenshot"
 	"github.com/nvlled/carrot"
 	"github.com/nvlled/screencage/lib/framerate"
+	"github.com/nvlled/screencage/lib/x11win"
 )
 
 type PngCapturer struct {
@@ -37,6 +38,16 @@ type PngCapturer struct {
 
 	lastDraw int64
 
+	// of the last series
+	stats framerate.Stats
+
+	// of the running schedule
+	nextShot time.Time
+
+	// the schedule was turned on with [d], and waits for [enter].
+	// A schedule enabled in the settings starts right away.
+	scheduleArmed bool
+
 	Err error
 }
 
@@ -54,33 +65,12 @@ type PngFrame struct {
 	CsDelay int
 }
 
-func ScreenshotAndSave(filename string) *Task[Void] {
+// SaveOnePng writes img to file. The file is closed afterwards.
+func SaveOnePng(file *os.File, img *image.RGBA) *Task[Void] {
 	task := &Task[Void]{}
 	go func() {
 		defer task.Finish()
-		bounds := GetWindowBounds()
-		image, err := screenshot.CaptureRect(bounds)
-		if err != nil {
-			task.Err = err
-			return
-		}
-		file, err := os.OpenFile(filename, os.O_CREATE|os.O_WRONLY, 0644)
-		if err != nil {
-			task.Err = err
-			return
-		}
-
 		defer file.Close()
-		task.Err = png.Encode(file, image)
-	}()
-
-	return task
-}
-
-func SaveOnePng(filename string, img *image.RGBA) *Task[Void] {
-	task := &Task[Void]{}
-	go func() {
-		defer task.Finish()
 
 		quantizer := quantize.MedianCutQuantizer{}
 		emptyPalette := make([]color.Color, 0, 256)
@@ -89,12 +79,7 @@ func SaveOnePng(filename string, img *image.RGBA) *Task[Void] {
 		palleted := image.NewPaletted(img.Rect, pal)
 		draw.Src.Draw(palleted, img.Bounds(), img, image.Point{})
 
-		file, err := os.OpenFile(filename, os.O_CREATE|os.O_WRONLY, 0644)
-		if err != nil {
-			task.Err = err
-		} else {
-			task.Err = png.Encode(file, img)
-		}
+		task.Err = png.Encode(file, img)
 	}()
 
 	return task
@@ -117,7 +102,10 @@ START:
 
 		for {
 			hasShot := false
-			if inpututil.IsKeyJustPressed(ebiten.KeySpace) {
+			if capturer.game.settings.Schedule.Enabled {
+				capturer.Err = capturer.startSchedule(ctrl)
+				hasShot = true
+			} else if inpututil.IsKeyJustPressed(ebiten.KeySpace) {
 				capturer.Err = capturer.startSingleScreenShot(ctrl)
 				hasShot = true
 			} else if inpututil.IsKeyJustPressed(ebiten.KeyEnter) || capturer.game.autoStart {
@@ -156,9 +144,19 @@ func (capturer *PngCapturer) startSingleScreenShot(ctrl *carrot.Control) error {
 	capturer.game.borderOnly = true
 	awaitNextDraw(ctrl, &capturer.lastDraw)
 
-	filename, _ := capturer.game.getNextOutFilename()
-	capturer.saveFilename = filename
-	task := ScreenshotAndSave(filename)
+	capturer.game.resetFrameCount()
+	img, err := capturer.game.captureFrame()
+	if err != nil {
+		capturer.game.borderOnly = false
+		return err
+	}
+	file, _, err := capturer.game.createOutputFile()
+	if err != nil {
+		capturer.game.borderOnly = false
+		return err
+	}
+	capturer.saveFilename = file.Name()
+	task := SaveOnePng(file, img)
 	log.Println("screenshot done")
 	ctrl.YieldUntil(task.IsDone)
 	capturer.game.borderOnly = false
@@ -170,14 +168,19 @@ func (capturer *PngCapturer) startSingleScreenShot(ctrl *carrot.Control) error {
 }
 
 func (capturer *PngCapturer) startMultiScreenShot(ctrl *carrot.Control) error {
-	capturer.saveFilename, capturer.imageCounter =
-		capturer.game.getNextOutFilename()
-
-	file, err := os.OpenFile(capturer.saveFilename, os.O_CREATE|os.O_WRONLY, 0644)
+	file, counter, err := capturer.game.createOutputFile()
 	if err != nil {
 		return err
 	}
-	defer file.Close()
+	capturer.saveFilename = file.Name()
+	capturer.imageCounter = counter
+	defer func() {
+		// nothing was saved to the reserved file
+		if file != nil {
+			file.Close()
+			os.Remove(file.Name())
+		}
+	}()
 
 	var savingCtrl carrot.SubControl
 
@@ -211,10 +214,15 @@ func (capturer *PngCapturer) startMultiScreenShot(ctrl *carrot.Control) error {
 					continue
 				}
 
-				filename := ReplaceIncrementedFilename(capturer.saveFilename, capturer.imageCounter)
-				capturer.imageCounter++
+				if file == nil {
+					file, err = capturer.createSeriesFile()
+					if err != nil {
+						return
+					}
+				}
 
-				task := SaveOnePng(filename, img)
+				task := SaveOnePng(file, img)
+				file = nil
 				ctrl.YieldUntil(task.IsDone)
 
 				if task.Err != nil {
@@ -227,13 +235,18 @@ func (capturer *PngCapturer) startMultiScreenShot(ctrl *carrot.Control) error {
 			}
 		})
 
-		awaitEnter(ctrl)
-		for !inpututil.IsKeyJustPressed(ebiten.KeyEnter) {
+		// the capture loop also stops by itself
+		// when the followed window is closed
+		ctrl.Yield()
+		for !inpututil.IsKeyJustPressed(ebiten.KeyEnter) && !screenShotCtrl.IsDone() {
 			if err != nil {
 				return err
 			}
 			ctrl.Yield()
 		}
+		if err != nil {
+			return err
+		}
 
 		screenShotCtrl.Cancel()
 
@@ -275,13 +288,39 @@ func (capturer *PngCapturer) startMultiScreenShot(ctrl *carrot.Control) error {
 	return nil
 }
 
+// createSeriesFile creates the file for the next image
+// in a series, numbered after the first saved file.
+func (capturer *PngCapturer) createSeriesFile() (*os.File, error) {
+	capturer.imageCounter++
+	nameOf := func(counter int) string {
+		return ReplaceIncrementedFilename(capturer.saveFilename, counter)
+	}
+
+	if capturer.game.settings.OutputMethod != OutputMethodNewFile {
+		return createTruncated(nameOf(capturer.imageCounter))
+	}
+
+	file, counter, err := CreateExclusiveFile(nameOf, capturer.imageCounter, func() (int, error) {
+		_, next := NextLatestIncrementedFilename(capturer.saveFilename)
+		return next - 1, nil
+	})
+	capturer.imageCounter = counter
+	return file, err
+}
+
 func (capturer *PngCapturer) startCaptureLoop(queue *Queue[*image.RGBA], ctrl *carrot.Control) error {
+	capturer.game.resetFrameCount()
 	rate := capturer.game.settings.FrameRate
-	frameDuration := rate.Duration()
-	for {
-		bounds := GetWindowBounds()
+	sched := framerate.NewScheduler(rate.Duration(), nil)
 
-		img, err := screenshot.CaptureRect(bounds)
+	for {
+		awaitTick(ctrl, sched)
+		capturer.stats = sched.Stats()
+		img, err := capturer.game.captureFrame()
+		if errors.Is(err, x11win.ErrWindowClosed) {
+			log.Println("* followed window closed")
+			return nil
+		}
 		if err != nil {
 			return err
 		}
@@ -290,7 +329,6 @@ func (capturer *PngCapturer) startCaptureLoop(queue *Queue[*image.RGBA], ctrl *c
 		log.Println("* screenshot", capturer.numImages)
 
 		queue.Push(img)
-		ctrl.Sleep(frameDuration)
 	}
 }
 
@@ -306,20 +344,21 @@ func (capturer *PngCapturer) Update() {
 
 		stepSize := 1
 		if ebiten.IsKeyPressed(ebiten.KeyShift) {
-			stepSize = 10
+			stepSize = 5
 		}
 		if inpututil.IsKeyJustPressed(ebiten.KeyDown) {
-			s.FrameRate.Value -= stepSize
+			s.FrameRate = s.FrameRate.Step(-stepSize)
 			capturer.game.scheduleSaveSettings()
 		} else if inpututil.IsKeyJustPressed(ebiten.KeyUp) {
-			s.FrameRate.Value += stepSize
+			s.FrameRate = s.FrameRate.Step(stepSize)
 			capturer.game.scheduleSaveSettings()
 		}
-		s.FrameRate.Clamp(1, 30)
-
-		if inpututil.IsKeyJustPressed(ebiten.KeyBackspace) {
-			s.FrameRate.Unit = (s.FrameRate.Unit + 1) % framerate.Unit_End
+		if inpututil.IsKeyJustPressed(ebiten.KeyD) {
+			s.Schedule.Enabled = !s.Schedule.Enabled
+			capturer.scheduleArmed = s.Schedule.Enabled
+			capturer.game.scheduleSaveSettings()
 		}
+		capturer.game.adjustFrameRate()
 	}
 }
 
@@ -335,8 +374,9 @@ func (capturer *PngCapturer) drawInactive(screen *ebiten.Image) {
 	scrp.Font = capturer.game.smallFont
 	scrp.Println("\n\n")
 	scrp.Printf("rate: %v", s.FrameRate.String())
-	scrp.Printf("controls: [up][down] or [backspace]")
+	scrp.Printf("controls: [up][down]")
 	scrp.Printf("shift can be used [up][down]")
+	scrp.Printf("schedule [d]: %v", s.Schedule)
 	scrp.Font = capturer.game.tinyFont
 
 	scrp.Println("\n\n")
@@ -375,6 +415,10 @@ func (capturer *PngCapturer) drawSaved(screen *ebiten.Image) {
 	capturer.scrp.Println("Done!")
 	scrp.Color = ColorWhite
 	capturer.scrp.Println("Press [enter] to continue")
+	if capturer.stats.Frames > 1 {
+		scrp.Font = capturer.game.smallFont
+		scrp.Println(capturer.stats.String())
+	}
 }
 
 func (capturer *PngCapturer) drawError(screen *ebiten.Image) {
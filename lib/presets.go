@@ -0,0 +1,105 @@
+package lib
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+// Preset is a saved window position and size. Presets are
+// saved and recalled by their number, the position in
+// Settings.Presets plus one. The name is given with
+// -preset-name when saving, or edited in the settings.
+type Preset struct {
+	Name string `json:"name"`
+	Rect Rect   `json:"rect"`
+}
+
+var presetKeys = []ebiten.Key{
+	ebiten.Key1, ebiten.Key2, ebiten.Key3,
+	ebiten.Key4, ebiten.Key5, ebiten.Key6,
+	ebiten.Key7, ebiten.Key8, ebiten.Key9,
+}
+
+func (preset Preset) IsEmpty() bool {
+	return preset.Rect.W == 0 || preset.Rect.H == 0
+}
+
+func (preset Preset) String() string {
+	return fmt.Sprintf("%v (%vx%v)", preset.Name, preset.Rect.W, preset.Rect.H)
+}
+
+// FindPreset finds a preset by name, or by number.
+func FindPreset(presets []Preset, name string) (Preset, bool) {
+	for _, preset := range presets {
+		if !preset.IsEmpty() && strings.EqualFold(preset.Name, name) {
+			return preset, true
+		}
+	}
+	if n, err := strconv.Atoi(name); err == nil && n >= 1 && n <= len(presets) {
+		preset := presets[n-1]
+		return preset, !preset.IsEmpty()
+	}
+	return Preset{}, false
+}
+
+// updatePresets saves the window rect with ctrl+number,
+// and moves the window to a preset with number.
+func (g *App) updatePresets() {
+	for i, key := range presetKeys {
+		if !inpututil.IsKeyJustPressed(key) {
+			continue
+		}
+		if ebiten.IsKeyPressed(ebiten.KeyControl) {
+			g.savePreset(i)
+		} else if i < len(g.settings.Presets) {
+			g.applyPreset(g.settings.Presets[i])
+		}
+	}
+}
+
+func (g *App) savePreset(i int) {
+	s := &g.settings
+	for len(s.Presets) <= i {
+		s.Presets = append(s.Presets, Preset{})
+	}
+	preset := &s.Presets[i]
+	if g.presetSaveName != "" {
+		preset.Name = g.presetSaveName
+		g.presetSaveName = ""
+	} else if preset.Name == "" {
+		preset.Name = fmt.Sprintf("preset %v", i+1)
+	}
+	preset.Rect = s.WindowRect
+	g.scheduleSaveSettings()
+}
+
+func (g *App) applyPreset(preset Preset) {
+	if preset.IsEmpty() {
+		return
+	}
+	r := preset.Rect
+	ebiten.SetWindowPosition(r.X, r.Y)
+	ebiten.SetWindowSize(r.W, r.H)
+	g.settings.WindowRect = r
+	g.scheduleSaveSettings()
+}
+
+// presetList lists the saved presets for the overlay.
+func (g *App) presetList() string {
+	var items []string
+	for i, preset := range g.settings.Presets {
+		if !preset.IsEmpty() {
+			items = append(items, fmt.Sprintf("%v %v", i+1, preset))
+		}
+	}
+	if g.presetSaveName != "" {
+		items = append(items, fmt.Sprintf("[ctrl+1-9] saves %q", g.presetSaveName))
+	} else if len(items) == 0 {
+		return "none, save with [ctrl+1-9]"
+	}
+	return strings.Join(items, ", ")
+}
@@ -8,6 +8,9 @@ type Queue[T any] struct {
 	pushIndex int
 	mu        sync.Mutex
 
+	// 0 means the queue grows without bound
+	maxSize int
+
 	defaultValue T
 }
 
@@ -19,10 +22,27 @@ func CreateQueue[T any](initSize int) Queue[T] {
 	}
 }
 
+// CreateBoundedQueue returns a queue that holds at most maxSize
+// items, pushing to a full queue drops the oldest item.
+func CreateBoundedQueue[T any](maxSize int) Queue[T] {
+	if maxSize < 1 {
+		maxSize = 1
+	}
+	return Queue[T]{
+		// one more, since a full ring would look empty
+		data:    make([]T, maxSize+1),
+		maxSize: maxSize,
+	}
+}
+
 func (q *Queue[T]) Push(item T) {
 	q.mu.Lock()
 	defer q.mu.Unlock()
 
+	if q.maxSize > 0 && q.Size() >= q.maxSize {
+		q.dropOldest()
+	}
+
 	nextPushIndex := (q.pushIndex + 1) % len(q.data)
 
 	size := q.Size()
@@ -51,14 +71,36 @@ func (q *Queue[T]) Pop() (T, bool) {
 	}
 
 	value := q.data[q.popIndex]
+	q.dropOldest()
+
+	return value, true
+}
+
+func (q *Queue[T]) dropOldest() {
 	q.data[q.popIndex] = q.defaultValue
 	q.popIndex++
 
 	if q.popIndex >= len(q.data) {
 		q.popIndex = 0
 	}
+}
 
-	return value, true
+// Snapshot returns a copy of the items, from the oldest,
+// without removing them.
+func (q *Queue[T]) Snapshot() []T {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	items := make([]T, 0, q.Size())
+	for i := q.popIndex; i != q.pushIndex; i = (i + 1) % len(q.data) {
+		items = append(items, q.data[i])
+	}
+	return items
+}
+
+// MaxSize returns the bound of the queue, or 0 if it has none.
+func (q *Queue[T]) MaxSize() int {
+	return q.maxSize
 }
 
 func (q *Queue[T]) Size() int {
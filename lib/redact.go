@@ -0,0 +1,232 @@
+package lib
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+// Redaction is an area that is hidden in every captured frame,
+// before the frame is saved.
+type Redaction struct {
+	// Relative to the capture area, in physical pixels.
+	Rect Rect       `json:"rect"`
+	Mode RedactMode `json:"mode"`
+}
+
+type RedactMode int
+
+const (
+	RedactPixelate RedactMode = iota
+	RedactBlur
+	RedactBlack
+
+	RedactMode_Size
+)
+
+func (mode RedactMode) String() string {
+	switch mode {
+	case RedactPixelate:
+		return "pixelate"
+	case RedactBlur:
+		return "blur"
+	case RedactBlack:
+		return "black"
+	}
+	return "invalid-redact-mode"
+}
+
+const (
+	pixelateSize = 12
+	blurRadius   = 8
+)
+
+func (r Rect) Rectangle() image.Rectangle {
+	return image.Rect(r.X, r.Y, r.X+r.W, r.Y+r.H)
+}
+
+func RectFromRectangle(r image.Rectangle) Rect {
+	r = r.Canon()
+	return Rect{X: r.Min.X, Y: r.Min.Y, W: r.Dx(), H: r.Dy()}
+}
+
+// ApplyRedactions hides the redacted areas of img. offset is where
+// the capture area is in img, which isn't at the top left when
+// capturing more than the cage, or less, when it's clipped.
+func ApplyRedactions(img *image.RGBA, redactions []Redaction, offset image.Point) {
+	for _, redaction := range redactions {
+		r := redaction.Rect.Rectangle().Add(img.Rect.Min.Add(offset)).Intersect(img.Rect)
+		if r.Empty() {
+			continue
+		}
+		switch redaction.Mode {
+		case RedactPixelate:
+			pixelate(img, r, pixelateSize)
+		case RedactBlur:
+			// three box blurs are close enough to a gaussian blur
+			for i := 0; i < 3; i++ {
+				boxBlur(img, r, blurRadius)
+			}
+		default:
+			draw.Draw(img, r, image.NewUniform(ColorBlack), image.Point{}, draw.Src)
+		}
+	}
+}
+
+func pixelate(img *image.RGBA, r image.Rectangle, size int) {
+	for y := r.Min.Y; y < r.Max.Y; y += size {
+		for x := r.Min.X; x < r.Max.X; x += size {
+			block := image.Rect(x, y, x+size, y+size).Intersect(r)
+			var sum [4]int
+			for by := block.Min.Y; by < block.Max.Y; by++ {
+				for bx := block.Min.X; bx < block.Max.X; bx++ {
+					i := img.PixOffset(bx, by)
+					for c := 0; c < 4; c++ {
+						sum[c] += int(img.Pix[i+c])
+					}
+				}
+			}
+			n := block.Dx() * block.Dy()
+			avg := color.RGBA{
+				uint8(sum[0] / n), uint8(sum[1] / n),
+				uint8(sum[2] / n), uint8(sum[3] / n),
+			}
+			draw.Draw(img, block, image.NewUniform(avg), image.Point{}, draw.Src)
+		}
+	}
+}
+
+// boxBlur blurs r horizontally, then vertically.
+func boxBlur(img *image.RGBA, r image.Rectangle, radius int) {
+	w, h := r.Dx(), r.Dy()
+	line := make([][4]int, 0, w+h)
+
+	blurLine := func(n int, offset func(i int) int) {
+		line = line[:0]
+		for i := 0; i < n; i++ {
+			o := offset(i)
+			line = append(line, [4]int{
+				int(img.Pix[o]), int(img.Pix[o+1]),
+				int(img.Pix[o+2]), int(img.Pix[o+3]),
+			})
+		}
+		for i := 0; i < n; i++ {
+			var sum [4]int
+			from, to := i-radius, i+radius
+			if from < 0 {
+				from = 0
+			}
+			if to > n-1 {
+				to = n - 1
+			}
+			for j := from; j <= to; j++ {
+				for c := 0; c < 4; c++ {
+					sum[c] += line[j][c]
+				}
+			}
+			o := offset(i)
+			for c := 0; c < 4; c++ {
+				img.Pix[o+c] = uint8(sum[c] / (to - from + 1))
+			}
+		}
+	}
+
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		blurLine(w, func(i int) int { return img.PixOffset(r.Min.X+i, y) })
+	}
+	for x := r.Min.X; x < r.Max.X; x++ {
+		blurLine(h, func(i int) int { return img.PixOffset(x, r.Min.Y+i) })
+	}
+}
+
+// RedactionEditor lets the user draw redactions on the window
+// with the mouse. Drag to add one, right click to remove one.
+type RedactionEditor struct {
+	Active bool
+
+	// mode of the next redaction
+	Mode RedactMode
+
+	dragging bool
+	start    image.Point
+	current  image.Point
+}
+
+func (editor *RedactionEditor) Update(g *App) {
+	s := &g.settings
+
+	if inpututil.IsKeyJustPressed(ebiten.KeyM) {
+		editor.Mode = (editor.Mode + 1) % RedactMode_Size
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyDelete) && len(s.Redactions) > 0 {
+		s.Redactions = nil
+		g.scheduleSaveSettings()
+	}
+
+	x, y := ebiten.CursorPosition()
+	cursor := windowToCapture(image.Pt(x, y), ebiten.DeviceScaleFactor())
+
+	if inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonRight) {
+		for i := len(s.Redactions) - 1; i >= 0; i-- {
+			if cursor.In(s.Redactions[i].Rect.Rectangle()) {
+				s.Redactions = append(s.Redactions[:i], s.Redactions[i+1:]...)
+				g.scheduleSaveSettings()
+				break
+			}
+		}
+	}
+
+	if inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) {
+		editor.dragging = true
+		editor.start = cursor
+	}
+	editor.current = cursor
+
+	if editor.dragging && !ebiten.IsMouseButtonPressed(ebiten.MouseButtonLeft) {
+		editor.dragging = false
+		r := image.Rectangle{editor.start, editor.current}.Canon()
+		if r.Dx() > 2 && r.Dy() > 2 {
+			s.Redactions = append(s.Redactions, Redaction{
+				Rect: RectFromRectangle(r),
+				Mode: editor.Mode,
+			})
+			g.scheduleSaveSettings()
+		}
+	}
+}
+
+func (editor *RedactionEditor) Draw(screen *ebiten.Image, g *App) {
+	scrp := g.scrp
+	scrp.AlignX = 0b11
+	scrp.Font = g.regularFont
+	scrp.Color = ColorRed
+	scrp.Println("Redactions")
+	scrp.Color = ColorWhite
+	scrp.Font = g.smallFont
+	scrp.Println("Drag to hide an area, right click to remove")
+	scrp.Printf("mode [m]: %v", editor.Mode)
+	scrp.Println("clear all [delete], done [r]")
+
+	if editor.dragging {
+		r := image.Rectangle{editor.start, editor.current}.Canon()
+		drawRedactionRect(screen, r, editor.Mode.String())
+	}
+}
+
+// drawRedactions shows the redacted areas on the window.
+func drawRedactions(screen *ebiten.Image, g *App) {
+	for _, redaction := range g.settings.Redactions {
+		drawRedactionRect(screen, redaction.Rect.Rectangle(), redaction.Mode.String())
+	}
+}
+
+func drawRedactionRect(screen *ebiten.Image, r image.Rectangle, label string) {
+	scale := ebiten.DeviceScaleFactor()
+	r = image.Rectangle{captureToWindow(r.Min, scale), captureToWindow(r.Max, scale)}
+	ebitenutil.DrawRect(screen, float64(r.Min.X), float64(r.Min.Y), float64(r.Dx()), float64(r.Dy()), color.RGBA{120, 0, 0, 120})
+	ebitenutil.DebugPrintAt(screen, label, r.Min.X+2, r.Min.Y+2)
+}
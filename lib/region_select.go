@@ -0,0 +1,155 @@
+package lib
+
+import (
+	"fmt"
+	"image"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+// RegionSelector lets the user choose the capture area by
+// dragging a rectangle over the whole screen. The window goes
+// fullscreen while selecting, then is moved and resized so that
+// its capture area matches the selection.
+type RegionSelector struct {
+	Active bool
+
+	// window position and size before selecting
+	prevRect Rect
+
+	dragging  bool
+	start     image.Point
+	selection image.Rectangle
+}
+
+func (selector *RegionSelector) Start(g *App) {
+	selector.Active = true
+	selector.prevRect = g.settings.WindowRect
+	selector.dragging = false
+	selector.selection = image.Rectangle{}
+	ebiten.SetFullscreen(true)
+}
+
+// Confirm snaps the window to the selection.
+func (selector *RegionSelector) Confirm(g *App) {
+	selector.Active = false
+	ebiten.SetFullscreen(false)
+
+	r := selector.selection.Canon()
+	if r.Empty() {
+		selector.restore()
+		return
+	}
+	ebiten.SetWindowPosition(r.Min.X-captureInset, r.Min.Y-captureInset)
+	ebiten.SetWindowSize(r.Dx()+captureInset*2, r.Dy()+captureInset*2)
+	g.scheduleSaveSettings()
+}
+
+func (selector *RegionSelector) Cancel() {
+	selector.Active = false
+	ebiten.SetFullscreen(false)
+	selector.restore()
+}
+
+func (selector *RegionSelector) restore() {
+	wr := selector.prevRect
+	ebiten.SetWindowPosition(wr.X, wr.Y)
+	ebiten.SetWindowSize(wr.W, wr.H)
+}
+
+func (selector *RegionSelector) Update(g *App) {
+	if inpututil.IsKeyJustPressed(ebiten.KeyEscape) {
+		selector.Cancel()
+		return
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyEnter) {
+		selector.Confirm(g)
+		return
+	}
+
+	x, y := ebiten.CursorPosition()
+	cursor := image.Pt(x, y)
+
+	if inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) {
+		selector.dragging = true
+		selector.start = cursor
+	}
+	if selector.dragging {
+		selector.selection = image.Rectangle{selector.start, cursor}.Canon()
+		if !ebiten.IsMouseButtonPressed(ebiten.MouseButtonLeft) {
+			selector.dragging = false
+		}
+		return
+	}
+
+	// arrow keys move the selection, or resize it with shift
+	var delta image.Point
+	switch {
+	case keyRepeated(ebiten.KeyArrowLeft):
+		delta.X = -1
+	case keyRepeated(ebiten.KeyArrowRight):
+		delta.X = 1
+	case keyRepeated(ebiten.KeyArrowUp):
+		delta.Y = -1
+	case keyRepeated(ebiten.KeyArrowDown):
+		delta.Y = 1
+	}
+	if ebiten.IsKeyPressed(ebiten.KeyControl) {
+		delta = delta.Mul(10)
+	}
+	if ebiten.IsKeyPressed(ebiten.KeyShift) {
+		selector.selection.Max = selector.selection.Max.Add(delta)
+		selector.selection = selector.selection.Canon()
+	} else {
+		selector.selection = selector.selection.Add(delta)
+	}
+}
+
+func (selector *RegionSelector) Draw(screen *ebiten.Image, g *App) {
+	b := screen.Bounds()
+	r := selector.selection.Canon().Intersect(b)
+	shade := ColorBlackTransparent
+
+	// shade everything except the selection
+	ebitenutil.DrawRect(screen, 0, 0, float64(b.Dx()), float64(r.Min.Y), shade)
+	ebitenutil.DrawRect(screen, 0, float64(r.Max.Y), float64(b.Dx()), float64(b.Max.Y-r.Max.Y), shade)
+	ebitenutil.DrawRect(screen, 0, float64(r.Min.Y), float64(r.Min.X), float64(r.Dy()), shade)
+	ebitenutil.DrawRect(screen, float64(r.Max.X), float64(r.Min.Y), float64(b.Max.X-r.Max.X), float64(r.Dy()), shade)
+
+	scrp := g.scrp
+	scrp.AlignX = 0b11
+	scrp.Font = g.regularFont
+	scrp.Color = ColorTeal
+	scrp.Println("Select region")
+	scrp.Font = g.smallFont
+	scrp.Color = ColorWhite
+	scrp.Println("Drag to select, arrows to move, shift+arrows to resize")
+	scrp.Println("confirm [enter], cancel [esc]")
+
+	if r.Empty() {
+		return
+	}
+
+	ebitenutil.DrawRect(screen, float64(r.Min.X), float64(r.Min.Y), float64(r.Dx()), 1, ColorTeal)
+	ebitenutil.DrawRect(screen, float64(r.Min.X), float64(r.Max.Y-1), float64(r.Dx()), 1, ColorTeal)
+	ebitenutil.DrawRect(screen, float64(r.Min.X), float64(r.Min.Y), 1, float64(r.Dy()), ColorTeal)
+	ebitenutil.DrawRect(screen, float64(r.Max.X-1), float64(r.Min.Y), 1, float64(r.Dy()), ColorTeal)
+
+	// the size of the frames, in physical pixels
+	size := scalePoint(r.Size(), ebiten.DeviceScaleFactor())
+	readout := fmt.Sprintf("%vx%v at %v,%v", size.X, size.Y, r.Min.X, r.Min.Y)
+	textY := r.Max.Y + 4
+	if textY+16 > b.Max.Y {
+		textY = r.Min.Y - 20
+	}
+	ebitenutil.DebugPrintAt(screen, readout, r.Min.X, textY)
+}
+
+// keyRepeated reports whether the key was just pressed,
+// or is held long enough to repeat.
+func keyRepeated(key ebiten.Key) bool {
+	d := inpututil.KeyPressDuration(key)
+	return d == 1 || (d > 15 && d%2 == 0)
+}
@@ -0,0 +1,379 @@
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"log"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/nvlled/carrot"
+	gif "github.com/nvlled/gogif"
+	"github.com/nvlled/screencage/lib/framerate"
+	"github.com/nvlled/screencage/lib/globalkeys"
+	"github.com/nvlled/screencage/lib/x11win"
+)
+
+// ReplaySettings is for the replay mode, which keeps capturing the
+// last few seconds, and saves them to a new gif on [ctrl+alt+r].
+type ReplaySettings struct {
+	Enabled bool    `json:"enabled"`
+	Seconds float64 `json:"seconds"`
+}
+
+var defaultReplay = ReplaySettings{Seconds: 30}
+
+// the lengths stepped through with [ and ]
+var replayLengths = []float64{5, 10, 15, 30, 45, 60}
+
+// replaySaveHotkey is read from the whole desktop, since the app
+// under the cage has the focus. It has modifiers, so that it
+// doesn't go off while typing in that app.
+const replaySaveHotkey = "[ctrl+alt+r]"
+
+func isReplaySave(press globalkeys.Press) bool {
+	if press.Key != ebiten.KeyR {
+		return false
+	}
+	ctrl, alt := false, false
+	for _, key := range press.Held {
+		switch modifierName(key) {
+		case "Ctrl":
+			ctrl = true
+		case "Alt":
+			alt = true
+		}
+	}
+	return ctrl && alt
+}
+
+// replayOnly turns off timelapse and motion while the replay mode is
+// on. The replays are saved frame by frame as they were captured, so
+// they'd silently be left out.
+func (s *Settings) replayOnly() {
+	if s.Replay.Enabled {
+		s.Timelapse.Enabled = false
+		s.Motion.Enabled = false
+	}
+}
+
+func (s ReplaySettings) Length() time.Duration {
+	return time.Duration(s.Seconds * float64(time.Second))
+}
+
+// Step returns the settings with the length n steps longer,
+// or shorter if n is negative.
+func (s ReplaySettings) Step(n int) ReplaySettings {
+	i := 0
+	for i < len(replayLengths)-1 && replayLengths[i] < s.Seconds {
+		i++
+	}
+	i += n
+	if i < 0 {
+		i = 0
+	} else if i >= len(replayLengths) {
+		i = len(replayLengths) - 1
+	}
+	s.Seconds = replayLengths[i]
+	return s
+}
+
+func (s ReplaySettings) String() string {
+	if !s.Enabled {
+		return "off"
+	}
+	return fmt.Sprintf("last %vs", s.Seconds)
+}
+
+// maxReplayBytes bounds the memory taken by the replay buffer,
+// at a byte per pixel.
+const maxReplayBytes = 512 << 20
+
+// replayCapacity returns the number of frames the replay buffer keeps,
+// enough for the length at the interval, but no more than fit in
+// maxReplayBytes with frames of the size.
+func replayCapacity(length, interval time.Duration, frameSize image.Point) int {
+	n := int(length/interval) + 1
+	if pixels := frameSize.X * frameSize.Y; pixels > 0 && n > maxReplayBytes/pixels {
+		n = maxReplayBytes / pixels
+	}
+	if n < 1 {
+		n = 1
+	}
+	return n
+}
+
+// ReplayFrame is a frame in the replay buffer. Frames are kept
+// quantized, which takes a quarter of the memory.
+type ReplayFrame struct {
+	Image *image.Paletted
+
+	// time on the timeline
+	At time.Duration
+}
+
+// lastFrames returns the frames of the last length of the timeline.
+func lastFrames(frames []ReplayFrame, length time.Duration) []ReplayFrame {
+	if len(frames) == 0 {
+		return nil
+	}
+	end := frames[len(frames)-1].At
+	i := len(frames) - 1
+	for i > 0 && end-frames[i-1].At < length {
+		i--
+	}
+	return frames[i:]
+}
+
+// replayDelays returns the delay of each frame in centiseconds. The
+// first frame gets the interval, since what came before is not saved.
+func replayDelays(frames []ReplayFrame, interval time.Duration) []int {
+	delays := make([]int, len(frames))
+	prevCs := 0
+	for i, frame := range frames {
+		cs := int(frame.At / (10 * time.Millisecond))
+		if i == 0 {
+			prevCs = cs - int(interval/(10*time.Millisecond))
+		}
+		delay := cs - prevCs
+		if delay > 500 {
+			delay = 500
+		}
+		delays[i] = delay
+		prevCs = cs
+	}
+	return delays
+}
+
+// startReplay fills the replay buffer until [enter], saving the last
+// seconds on replaySaveHotkey. Nothing is drawn inside the cage,
+// where it would end up in the replays, even with [F10]. Where the
+// desktop keys can't be read, the hotkey needs the cage focused.
+func (capturer *GifCapturer) startReplay(ctrl *carrot.Control) error {
+	s := capturer.game.settings
+	interval := s.FrameRate.Duration()
+	bounds, _, err := capturer.game.captureArea()
+	if err != nil {
+		return err
+	}
+	w, h := s.Scale.ScaledSize(bounds.Dx(), bounds.Dy())
+	capacity := replayCapacity(s.Replay.Length(), interval, image.Pt(w, h))
+	buffer := CreateBoundedQueue[ReplayFrame](capacity)
+	capturer.replayLength = time.Duration(capacity-1) * interval
+	if capturer.replayLength < s.Replay.Length() {
+		log.Printf("* replay limited to %v by memory", capturer.replayLength)
+	}
+
+	log.Println("* start replay")
+	capturer.replay = &buffer
+	capturer.numReplays = 0
+	capturer.game.borderLight = ColorRed
+	capturer.game.borderDark = ColorRedDark
+	capturer.game.borderOnly = true
+	ebiten.SetWindowResizingMode(ebiten.WindowResizingModeDisabled)
+	ebiten.SetWindowDecorated(false)
+	capturer.draw = nil
+	capturer.running.Store(true)
+	defer func() {
+		capturer.replay = nil
+		capturer.game.borderOnly = false
+		ebiten.SetWindowResizingMode(ebiten.WindowResizingModeEnabled)
+	}()
+
+	keys, keysErr := globalkeys.Open()
+	if keysErr != nil {
+		log.Println("the replay hotkey only works while the cage is focused:", keysErr)
+	}
+	defer func() {
+		if keys != nil {
+			keys.Close()
+		}
+	}()
+
+	awaitNextDraw(ctrl, &capturer.lastDraw)
+
+	screenShotCtrl := ctrl.StartAsync(func(ctrl *carrot.Control) {
+		err = capturer.startReplayLoop(&buffer, ctrl)
+	})
+	defer screenShotCtrl.Cancel()
+
+	var saveTask *Task[string]
+	ctrl.Yield()
+	for !inpututil.IsKeyJustPressed(ebiten.KeyEnter) && !screenShotCtrl.IsDone() {
+		if err != nil {
+			return err
+		}
+		if saveTask != nil && saveTask.IsDone() {
+			if saveTask.Err != nil {
+				return saveTask.Err
+			}
+			capturer.saveFilename = saveTask.Result
+			capturer.numReplays++
+			capturer.replaySavedAt = time.Now()
+			log.Println("* saved replay to", saveTask.Result)
+			saveTask = nil
+		}
+		save := false
+		if keys != nil {
+			presses, err := keys.Presses()
+			if err != nil {
+				log.Println("the replay hotkey only works while the cage is focused:", err)
+				keys.Close()
+				keys = nil
+			}
+			for _, press := range presses {
+				save = save || isReplaySave(press)
+			}
+		} else {
+			save = inpututil.IsKeyJustPressed(ebiten.KeyR) &&
+				ebiten.IsKeyPressed(ebiten.KeyControl) && ebiten.IsKeyPressed(ebiten.KeyAlt)
+		}
+		// nothing to save until the first frame is in
+		if save && saveTask == nil && !buffer.IsEmpty() {
+			frames := lastFrames(buffer.Snapshot(), s.Replay.Length())
+			saveTask = capturer.saveReplay(frames, interval)
+		}
+		ctrl.Yield()
+	}
+
+	if saveTask != nil {
+		ctrl.YieldUntil(saveTask.IsDone)
+		if saveTask.Err != nil {
+			return saveTask.Err
+		}
+		capturer.saveFilename = saveTask.Result
+		capturer.numReplays++
+	}
+	if err != nil {
+		return err
+	}
+
+	log.Println("* stop replay")
+	capturer.replay = nil
+	capturer.game.borderOnly = false
+	capturer.draw = capturer.drawReplayDone
+	now := time.Now()
+	ctrl.Yield()
+	ctrl.YieldUntil(func() bool {
+		return inpututil.IsKeyJustPressed(ebiten.KeyEnter) || time.Since(now).Seconds() > 4
+	})
+	return nil
+}
+
+// startReplayLoop captures into the buffer. Frames are quantized right
+// away, when that is slower than the frame rate the ticks are dropped.
+func (capturer *GifCapturer) startReplayLoop(buffer *Queue[ReplayFrame], ctrl *carrot.Control) error {
+	capturer.game.resetFrameCount()
+	sched := framerate.NewScheduler(capturer.game.settings.FrameRate.Duration(), nil)
+	reserved := capturer.game.reservedPalette()
+
+	for {
+		at := awaitTick(ctrl, sched)
+		capturer.stats = sched.Stats()
+		img, err := capturer.game.captureFrame()
+		if errors.Is(err, x11win.ErrWindowClosed) {
+			log.Println("* followed window closed")
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		task := quantizeTask(img, reserved)
+		ctrl.YieldUntil(task.IsDone)
+		buffer.Push(ReplayFrame{Image: task.Result, At: at})
+	}
+}
+
+func quantizeTask(img *image.RGBA, reserved color.Palette) *Task[*image.Paletted] {
+	task := &Task[*image.Paletted]{}
+	go func() {
+		defer task.Finish()
+		task.Result = quantizeFrame(img, reserved)
+	}()
+	return task
+}
+
+// saveReplay encodes the frames to the next output file,
+// and returns the filename it was saved to.
+func (capturer *GifCapturer) saveReplay(frames []ReplayFrame, interval time.Duration) *Task[string] {
+	task := &Task[string]{}
+	if len(frames) == 0 {
+		task.Err = errors.New("the replay buffer is empty")
+		task.Finish()
+		return task
+	}
+
+	output, err := capturer.game.createPendingOutput()
+	if err != nil {
+		task.Err = err
+		task.Finish()
+		return task
+	}
+
+	go func() {
+		defer task.Finish()
+
+		encoder := gif.NewStreamEncoder(output, &gif.StreamEncoderOptions{})
+		delays := replayDelays(frames, interval)
+		for i, frame := range frames {
+			if err := encoder.Encode(frame.Image, delays[i], gif.DisposalNone); err != nil {
+				task.Err = err
+				break
+			}
+		}
+		if task.Err == nil {
+			task.Err = encoder.Close()
+		}
+		if task.Err != nil {
+			output.Discard()
+			return
+		}
+		task.Err = output.Commit()
+		task.Result = output.Target
+	}()
+	return task
+}
+
+// replayFill returns how much of the buffer is filled, from 0 to 1.
+func (capturer *GifCapturer) replayFill() float64 {
+	buffer := capturer.replay
+	if buffer == nil || buffer.MaxSize() == 0 {
+		return 0
+	}
+	return float64(buffer.Size()) / float64(buffer.MaxSize())
+}
+
+// drawReplayFill draws the fill level over the top border, which is
+// never captured, so it shows with border only. The whole border
+// flashes for a second after a replay is saved.
+func (capturer *GifCapturer) drawReplayFill(screen *ebiten.Image) {
+	w := float64(screen.Bounds().Dx())
+	if time.Since(capturer.replaySavedAt) < time.Second {
+		ebitenutil.DrawRect(screen, 0, 0, w, borderWidth, ColorWhite)
+		return
+	}
+	ebitenutil.DrawRect(screen, 0, 0, w*capturer.replayFill(), borderWidth, ColorGreen)
+}
+
+func (capturer *GifCapturer) drawReplayDone(screen *ebiten.Image) {
+	scrp := capturer.scrp
+	scrp.Color = ColorWhite
+	if capturer.numReplays == 0 {
+		scrp.Println("Nothing saved")
+	} else {
+		scrp.Println("Done!")
+	}
+	scrp.Println("Press [enter] to continue")
+	scrp.Font = capturer.game.smallFont
+	if capturer.numReplays > 0 {
+		scrp.Printf("saved %v replays, last to %v", capturer.numReplays, capturer.saveFilename)
+	}
+	if s := capturer.game.settings.Replay; capturer.replayLength < s.Length() {
+		scrp.Printf("replays were limited to %v by memory", capturer.replayLength)
+	}
+}
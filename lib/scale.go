@@ -0,0 +1,123 @@
+package lib
+
+import (
+	"fmt"
+	"image"
+
+	xdraw "golang.org/x/image/draw"
+)
+
+type ScaleSettings struct {
+	// 0 or 100 keeps the captured size.
+	Percent int `json:"percent"`
+
+	// The scaled frame is shrunk further to fit these, if set.
+	MaxWidth  int `json:"maxWidth"`
+	MaxHeight int `json:"maxHeight"`
+
+	Scaler Scaler `json:"scaler"`
+}
+
+var scalePercentPresets = []int{100, 75, 50, 33, 25}
+
+type Scaler int
+
+const (
+	ScalerBilinear Scaler = iota
+	ScalerNearest
+	ScalerCatmullRom
+
+	Scaler_Size
+)
+
+func (scaler Scaler) String() string {
+	switch scaler {
+	case ScalerBilinear:
+		return "bilinear"
+	case ScalerNearest:
+		return "nearest"
+	case ScalerCatmullRom:
+		return "catmull-rom"
+	}
+	return "invalid-scaler"
+}
+
+func (scaler Scaler) interpolator() xdraw.Interpolator {
+	switch scaler {
+	case ScalerNearest:
+		return xdraw.NearestNeighbor
+	case ScalerCatmullRom:
+		return xdraw.CatmullRom
+	}
+	return xdraw.BiLinear
+}
+
+func (s ScaleSettings) String() string {
+	percent := s.Percent
+	if percent <= 0 {
+		percent = 100
+	}
+	str := ""
+	if percent != 100 {
+		str = fmt.Sprintf("%v%%", percent)
+	}
+	if s.MaxWidth > 0 || s.MaxHeight > 0 {
+		if str != "" {
+			str += ", "
+		}
+		str += fmt.Sprintf("max %vx%v", s.MaxWidth, s.MaxHeight)
+	}
+	if str == "" {
+		return "none"
+	}
+	return fmt.Sprintf("%v (%v)", str, s.Scaler)
+}
+
+// NextPercent cycles through the percent presets.
+func (s *ScaleSettings) NextPercent() {
+	for i, percent := range scalePercentPresets {
+		if percent == s.Percent {
+			s.Percent = scalePercentPresets[(i+1)%len(scalePercentPresets)]
+			return
+		}
+	}
+	s.Percent = scalePercentPresets[1]
+}
+
+// ScaledSize returns the size of a frame of size w x h after scaling.
+func (s ScaleSettings) ScaledSize(w, h int) (int, int) {
+	sw, sh := w, h
+	if s.Percent > 0 && s.Percent != 100 {
+		sw = w * s.Percent / 100
+		sh = h * s.Percent / 100
+	}
+	if s.MaxWidth > 0 && sw > s.MaxWidth {
+		sh = sh * s.MaxWidth / sw
+		sw = s.MaxWidth
+	}
+	if s.MaxHeight > 0 && sh > s.MaxHeight {
+		sw = sw * s.MaxHeight / sh
+		sh = s.MaxHeight
+	}
+	if sw < 1 {
+		sw = 1
+	}
+	if sh < 1 {
+		sh = 1
+	}
+	return sw, sh
+}
+
+// ScaleImage resizes img according to the scale settings.
+// img is returned as is if the size doesn't change.
+func ScaleImage(img *image.RGBA, s ScaleSettings) *image.RGBA {
+	b := img.Bounds()
+	w, h := s.ScaledSize(b.Dx(), b.Dy())
+	if w == b.Dx() && h == b.Dy() {
+		return img
+	}
+
+	scaled := image.NewRGBA(image.Rect(0, 0, w, h))
+	s.Scaler.interpolator().Scale(scaled, scaled.Bounds(), img, b, xdraw.Src, nil)
+	return scaled
+}
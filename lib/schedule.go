@@ -0,0 +1,293 @@
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/nvlled/carrot"
+	"github.com/nvlled/screencage/lib/framerate"
+)
+
+// ScheduleSettings is for taking screenshots at a rate, only between
+// the start and stop times on the given days, png only. The schedule is
+// saved with the settings, so it picks up again after a restart.
+type ScheduleSettings struct {
+	Enabled bool `json:"enabled"`
+
+	// Times of day like "09:00". A stop before the start
+	// means the series runs overnight.
+	Start string `json:"start"`
+	Stop  string `json:"stop"`
+
+	// Like "mon", "tue", all days if empty.
+	Days []string `json:"days"`
+
+	// Like "1/5m".
+	Rate framerate.T `json:"rate"`
+
+	// Output template for the shots, see OutputTemplate.
+	// If empty, the output file with the date and time added.
+	Filename string `json:"filename"`
+}
+
+var defaultSchedule = ScheduleSettings{
+	Start: "09:00",
+	Stop:  "17:00",
+	Days:  []string{"mon", "tue", "wed", "thu", "fri"},
+	Rate:  framerate.Every(5 * time.Minute),
+}
+
+var weekdayNames = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// Schedule is the parsed ScheduleSettings.
+type Schedule struct {
+	// minutes since midnight
+	Start, Stop int
+	Days        [7]bool
+	Interval    time.Duration
+}
+
+func (s ScheduleSettings) Parse() (Schedule, error) {
+	var sched Schedule
+	var err error
+	if sched.Start, err = parseTimeOfDay(s.Start); err != nil {
+		return sched, err
+	}
+	if sched.Stop, err = parseTimeOfDay(s.Stop); err != nil {
+		return sched, err
+	}
+
+	if len(s.Days) == 0 {
+		for i := range sched.Days {
+			sched.Days[i] = true
+		}
+	}
+	for _, day := range s.Days {
+		found := false
+		for i, name := range weekdayNames {
+			if strings.EqualFold(name, day) {
+				sched.Days[i] = true
+				found = true
+			}
+		}
+		if !found {
+			return sched, fmt.Errorf("invalid day: %q, must be one of %v", day, strings.Join(weekdayNames, ", "))
+		}
+	}
+
+	if !s.Rate.Valid() {
+		return sched, fmt.Errorf("invalid schedule rate: %v", s.Rate)
+	}
+	sched.Interval = s.Rate.Duration()
+	return sched, nil
+}
+
+func parseTimeOfDay(str string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(str))
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day: %q, must be like 09:00", str)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// Next returns the time of the next shot at or after now, or
+// the zero time if there is none. Shots are counted from the start
+// of each day's series, so they land on the same times every day.
+func (sched Schedule) Next(now time.Time) time.Time {
+	y, m, d := now.Date()
+	// from yesterday, for a series that runs overnight
+	for offset := -1; offset <= 7; offset++ {
+		day := time.Date(y, m, d+offset, 0, 0, 0, 0, now.Location())
+		if !sched.Days[day.Weekday()] {
+			continue
+		}
+		start := time.Date(y, m, d+offset, sched.Start/60, sched.Start%60, 0, 0, now.Location())
+		stop := time.Date(y, m, d+offset, sched.Stop/60, sched.Stop%60, 0, 0, now.Location())
+		if !stop.After(start) {
+			stop = stop.AddDate(0, 0, 1)
+		}
+		if !now.Before(stop) {
+			continue
+		}
+		if !now.After(start) {
+			return start
+		}
+
+		n := (now.Sub(start) + sched.Interval - 1) / sched.Interval
+		if next := start.Add(n * sched.Interval); next.Before(stop) {
+			return next
+		}
+	}
+	return time.Time{}
+}
+
+func (s ScheduleSettings) String() string {
+	if !s.Enabled {
+		return "off"
+	}
+	days := "every day"
+	if len(s.Days) > 0 {
+		days = strings.Join(s.Days, ",")
+	}
+	return fmt.Sprintf("%v-%v %v, %v", s.Start, s.Stop, days, s.Rate)
+}
+
+// template returns the output template for the shots. Unless set, the
+// date and time are added to the output file where they are missing,
+// so that each shot gets a dated file.
+func (s ScheduleSettings) template(outputFilename string) string {
+	if s.Filename != "" {
+		return s.Filename
+	}
+	var missing []string
+	for _, name := range []string{"date", "time"} {
+		if !hasPlaceholder(outputFilename, name) {
+			missing = append(missing, "{"+name+"}")
+		}
+	}
+	if len(missing) == 0 {
+		return outputFilename
+	}
+	base, _ := TrimExt(outputFilename)
+	return base + "-" + strings.Join(missing, "-") + ".{ext}"
+}
+
+// startSchedule takes the scheduled shots until [enter],
+// which also turns off the schedule.
+func (capturer *PngCapturer) startSchedule(ctrl *carrot.Control) error {
+	s := &capturer.game.settings
+	sched, err := s.Schedule.Parse()
+	if err != nil {
+		s.Schedule.Enabled = false
+		capturer.game.scheduleSaveSettings()
+		return err
+	}
+	if capturer.scheduleArmed && !capturer.confirmSchedule(ctrl, sched) {
+		return nil
+	}
+
+	log.Println("* start schedule")
+	capturer.numImages = 0
+	capturer.game.borderLight = ColorRed
+	capturer.game.borderDark = ColorRedDark
+	capturer.game.borderOnly = true
+	capturer.draw = capturer.drawScheduled
+	capturer.running.Store(true)
+	defer func() {
+		capturer.game.borderOnly = false
+	}()
+
+	awaitNextDraw(ctrl, &capturer.lastDraw)
+	capturer.game.resetFrameCount()
+
+	next := sched.Next(time.Now())
+	for {
+		if next.IsZero() {
+			s.Schedule.Enabled = false
+			capturer.game.scheduleSaveSettings()
+			return errors.New("nothing is scheduled, no days are set")
+		}
+		capturer.nextShot = next
+
+		ctrl.Yield()
+		for time.Now().Before(next) {
+			if inpututil.IsKeyJustPressed(ebiten.KeyEnter) {
+				s.Schedule.Enabled = false
+				capturer.game.scheduleSaveSettings()
+				return nil
+			}
+			ctrl.Yield()
+		}
+
+		if err := capturer.takeScheduledShot(ctrl); err != nil {
+			return err
+		}
+
+		// skip the shots missed while saving
+		now := time.Now()
+		if !now.After(next) {
+			now = next.Add(time.Nanosecond)
+		}
+		next = sched.Next(now)
+	}
+}
+
+// confirmSchedule shows the schedule that was turned on with [d], and
+// waits for [enter] to start it. false means it was turned off again.
+func (capturer *PngCapturer) confirmSchedule(ctrl *carrot.Control, sched Schedule) bool {
+	capturer.scheduleArmed = false
+	capturer.draw = capturer.drawScheduleConfirm
+	for {
+		capturer.nextShot = sched.Next(time.Now())
+		ctrl.Yield()
+		if !capturer.game.settings.Schedule.Enabled {
+			return false
+		}
+		if inpututil.IsKeyJustPressed(ebiten.KeyEnter) {
+			return true
+		}
+	}
+}
+
+func (capturer *PngCapturer) takeScheduledShot(ctrl *carrot.Control) error {
+	g := capturer.game
+	img, err := g.captureFrame()
+	if err != nil {
+		return err
+	}
+
+	s := &g.settings
+	tmpl := NewOutputTemplate(s.Schedule.template(s.OutputFilename), OutputTypePng, GetWindowBounds())
+	file, _, err := CreateOutputFile(tmpl, OutputMethodNewFile)
+	if err != nil {
+		return err
+	}
+	capturer.saveFilename = file.Name()
+
+	task := SaveOnePng(file, img)
+	ctrl.YieldUntil(task.IsDone)
+	if task.Err != nil {
+		return task.Err
+	}
+	capturer.numImages++
+	log.Println("* scheduled shot", capturer.saveFilename)
+	return nil
+}
+
+func (capturer *PngCapturer) drawScheduleConfirm(screen *ebiten.Image) {
+	scrp := capturer.scrp
+	s := capturer.game.settings
+	scrp.Color = ColorTeal
+	scrp.Println("Schedule")
+	scrp.Color = ColorWhite
+	scrp.Println("Press [enter] to start the schedule")
+	scrp.Println("Press [d] to turn it off")
+	scrp.Font = capturer.game.smallFont
+	scrp.Println(s.Schedule.String())
+	if capturer.nextShot.IsZero() {
+		scrp.Println("nothing is scheduled, no days are set")
+	} else {
+		scrp.Printf("first shot: %v", capturer.nextShot.Format("Mon Jan 2 15:04:05"))
+	}
+	scrp.Printf("saved to %v", s.Schedule.template(s.OutputFilename))
+}
+
+func (capturer *PngCapturer) drawScheduled(screen *ebiten.Image) {
+	scrp := capturer.scrp
+	s := capturer.game.settings
+	scrp.Color = ColorGreen
+	scrp.Println("Scheduled")
+	scrp.Color = ColorWhite
+	scrp.Println("Press [enter] to stop the schedule")
+	scrp.Font = capturer.game.smallFont
+	scrp.Println(s.Schedule.String())
+	scrp.Printf("next shot: %v", capturer.nextShot.Format("Mon 15:04:05"))
+	if capturer.numImages > 0 {
+		scrp.Printf("%v shots, last to %v", capturer.numImages, capturer.saveFilename)
+	}
+}
@@ -2,18 +2,25 @@ package lib
 
 import (
 	"fmt"
+	"image"
 	"image/color"
+	"image/draw"
 	"strings"
 
 	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
 	"github.com/hajimehoshi/ebiten/v2/text"
 	"golang.org/x/image/font"
+	"golang.org/x/image/math/fixed"
 )
 
 type ScreenPrint struct {
 	currentY int32
 	image    *ebiten.Image
 
+	// set by ResetFrame, drawn to instead of image
+	frame draw.Image
+
 	savedContext int64
 
 	Color color.Color
@@ -28,6 +35,9 @@ type ScreenPrint struct {
 
 	Border      int
 	LineSpacing int
+
+	// drawn behind each line if not nil
+	Background color.Color
 }
 
 func NewScreenPrint() *ScreenPrint {
@@ -39,6 +49,44 @@ func NewScreenPrint() *ScreenPrint {
 func (scrp *ScreenPrint) Reset(screen *ebiten.Image) {
 	scrp.currentY = 0
 	scrp.image = screen
+	scrp.frame = nil
+}
+
+// ResetFrame is like Reset, but for printing
+// on captured frames instead of the window.
+func (scrp *ScreenPrint) ResetFrame(frame draw.Image) {
+	scrp.currentY = 0
+	scrp.image = nil
+	scrp.frame = frame
+}
+
+func (scrp *ScreenPrint) bounds() image.Rectangle {
+	if scrp.frame != nil {
+		return scrp.frame.Bounds()
+	}
+	return scrp.image.Bounds()
+}
+
+func (scrp *ScreenPrint) drawText(str string, x, y int, c color.Color) {
+	if scrp.frame == nil {
+		text.Draw(scrp.image, str, scrp.Font, x, y, c)
+		return
+	}
+	drawer := font.Drawer{
+		Dst:  scrp.frame,
+		Src:  image.NewUniform(c),
+		Face: scrp.Font,
+		Dot:  fixed.P(x, y),
+	}
+	drawer.DrawString(str)
+}
+
+func (scrp *ScreenPrint) drawRect(r image.Rectangle, c color.Color) {
+	if scrp.frame == nil {
+		ebitenutil.DrawRect(scrp.image, float64(r.Min.X), float64(r.Min.Y), float64(r.Dx()), float64(r.Dy()), c)
+		return
+	}
+	draw.Draw(scrp.frame, r, image.NewUniform(c), image.Point{}, draw.Over)
 }
 
 func (scrp *ScreenPrint) Println(str string) {
@@ -48,7 +96,7 @@ func (scrp *ScreenPrint) Println(str string) {
 			line = " "
 		}
 		textB := text.BoundString(font, line)
-		imageB := scrp.image.Bounds()
+		imageB := scrp.bounds()
 
 		x := scrp.Border / 2
 		if scrp.AlignX&0b11 == 0b11 {
@@ -64,7 +112,13 @@ func (scrp *ScreenPrint) Println(str string) {
 			textColor = color.Black
 		}
 
-		text.Draw(scrp.image, line, font, x, y, textColor)
+		if scrp.Background != nil {
+			pad := scrp.LineSpacing / 2
+			box := textB.Add(image.Pt(x, y)).Inset(-pad)
+			scrp.drawRect(box, scrp.Background)
+		}
+
+		scrp.drawText(line, x, y, textColor)
 		scrp.currentY += int32(textB.Dy() + scrp.LineSpacing)
 
 	}
@@ -84,7 +138,7 @@ func (scrp *ScreenPrint) PrintAt(align byte, str string) {
 
 	scrp.AlignX = align >> 2
 	textB := text.BoundString(scrp.Font, str)
-	imageB := scrp.image.Bounds()
+	imageB := scrp.bounds()
 
 	scrp.currentY = int32(scrp.Border / 2)
 	if align&0b11 == 0b11 {
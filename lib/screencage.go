@@ -4,19 +4,23 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"image"
 	"image/color"
 	"io/fs"
 	"log"
 	"os"
 	"path/filepath"
 	"runtime/debug"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/hajimehoshi/ebiten/examples/resources/fonts"
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
 	"github.com/hajimehoshi/ebiten/v2/inpututil"
 	"github.com/nvlled/screencage/lib/framerate"
+	"github.com/nvlled/screencage/lib/globalkeys"
 	"github.com/sqweek/dialog"
 	"golang.org/x/image/font"
 	"golang.org/x/image/font/opentype"
@@ -32,8 +36,35 @@ type App struct {
 	smallFont   font.Face
 	tinyFont    font.Face
 
+	font      *opentype.Font
+	fontFaces map[float64]font.Face
+
 	scrp *ScreenPrint
 
+	// for printing on the captured frames
+	framePrint   *ScreenPrint
+	keystrokes   KeystrokeLog
+	keySource    *globalkeys.Source
+	keySourceErr error
+
+	frameCount  int
+	recordStart time.Time
+	// the capture area of the first frame
+	recordArea frameArea
+
+	logo *Logo
+
+	redactEditor   RedactionEditor
+	regionSelector RegionSelector
+
+	displays []image.Rectangle
+	// index of the display the window is on
+	display int
+
+	follow     *followState
+	followPick *Task[*followState]
+	followErr  error
+
 	settingFilename string
 	outputFilename  string
 	settings        Settings
@@ -52,6 +83,15 @@ type App struct {
 
 	autoStart    bool
 	exitOnFinish bool
+
+	// from the command line, applied after loading the settings
+	presetName     string
+	displayOption  string
+	straddleOption string
+	followOption   string
+
+	// the name of the next preset saved with [ctrl+1-9]
+	presetSaveName string
 }
 
 func NewGame() *App {
@@ -59,6 +99,7 @@ func NewGame() *App {
 		borderLight: ColorTeal,
 		borderDark:  ColorTealDark,
 		scrp:        NewScreenPrint(),
+		framePrint:  NewScreenPrint(),
 	}
 	game.gifCapturer = NewGifCapturer(game)
 	game.pngCapturer = NewPngCapturer(game)
@@ -67,10 +108,20 @@ func NewGame() *App {
 
 func (g *App) Update() error {
 	g.tickCounter++
+	g.updateKeystrokes()
 
 	if g.mustSaveSettings && g.tickCounter%50 == 0 { // throttle by 50 frames
 		g.onSettingsChanged()
 	}
+	if g.tickCounter%30 == 0 {
+		g.updateDisplays()
+	}
+	g.updateFollow()
+
+	if g.regionSelector.Active {
+		g.regionSelector.Update(g)
+		return nil
+	}
 
 	if inpututil.IsKeyJustPressed(ebiten.KeyF10) {
 		g.borderOnly = !g.borderOnly
@@ -91,6 +142,10 @@ func (g *App) Update() error {
 		}
 	}
 
+	if g.capturer != nil && g.capturer.IsRunning() && g.settings.Tracking == TrackingPan {
+		g.panCage()
+	}
+
 	if g.capturer == nil || !g.capturer.IsRunning() {
 		if inpututil.IsKeyJustPressed(ebiten.KeyF8) {
 			s := &g.settings
@@ -104,6 +159,60 @@ func (g *App) Update() error {
 			s := &g.settings
 			s.OutputMethod = (s.OutputMethod + 1) % OutputMethod_Size
 		}
+		if inpututil.IsKeyJustPressed(ebiten.KeyF2) {
+			g.settings.Cursor.Next()
+			g.scheduleSaveSettings()
+		}
+		if inpututil.IsKeyJustPressed(ebiten.KeyF3) {
+			g.settings.Keystrokes.Show = !g.settings.Keystrokes.Show
+			g.keySourceErr = nil
+			g.scheduleSaveSettings()
+		}
+		if inpututil.IsKeyJustPressed(ebiten.KeyF4) {
+			g.settings.Stamp.Show = !g.settings.Stamp.Show
+			g.scheduleSaveSettings()
+		}
+		if inpututil.IsKeyJustPressed(ebiten.KeyF6) {
+			g.settings.Scale.NextPercent()
+			g.scheduleSaveSettings()
+		}
+		if inpututil.IsKeyJustPressed(ebiten.KeyF7) {
+			s := &g.settings.Scale
+			s.Scaler = (s.Scaler + 1) % Scaler_Size
+			g.scheduleSaveSettings()
+		}
+		if inpututil.IsKeyJustPressed(ebiten.KeyF1) {
+			g.nextDisplay()
+		}
+		if inpututil.IsKeyJustPressed(ebiten.KeyW) {
+			g.toggleFollow()
+		}
+		if inpututil.IsKeyJustPressed(ebiten.KeyP) {
+			s := &g.settings
+			s.Tracking = (s.Tracking + 1) % TrackingMode_Size
+			g.scheduleSaveSettings()
+		}
+		if inpututil.IsKeyJustPressed(ebiten.KeyF11) {
+			s := &g.settings
+			s.SizeLock = s.SizeLock.Next()
+			g.constrainWindowSize(s.WindowRect.W, s.WindowRect.H)
+			g.scheduleSaveSettings()
+		}
+		if inpututil.IsKeyJustPressed(ebiten.KeyR) {
+			g.redactEditor.Active = !g.redactEditor.Active
+		}
+		if !g.redactEditor.Active {
+			g.updatePresets()
+		}
+		if inpututil.IsKeyJustPressed(ebiten.KeyS) && !g.redactEditor.Active {
+			g.regionSelector.Start(g)
+			return nil
+		}
+	}
+
+	if g.redactEditor.Active {
+		g.redactEditor.Update(g)
+		return nil
 	}
 
 	if g.capturer != nil {
@@ -117,7 +226,7 @@ func (g *App) drawBorder(screen *ebiten.Image) {
 	b := screen.Bounds()
 
 	sw, sh := float64(b.Dx()-1), float64(b.Dy()-1)
-	colors := []*ebiten.Image{lightBorderImage, darkBorderImage}
+	colors := [borderWidth]*ebiten.Image{lightBorderImage, darkBorderImage}
 
 	for i, c := range colors {
 		_ = i
@@ -152,6 +261,11 @@ func (g *App) drawBorder(screen *ebiten.Image) {
 func (g *App) Draw(screen *ebiten.Image) {
 	g.scrp.Reset(screen)
 
+	if g.regionSelector.Active {
+		g.regionSelector.Draw(screen, g)
+		return
+	}
+
 	if !g.borderOnly {
 		b := screen.Bounds()
 		ebitenutil.DrawRect(screen, 0, 0, float64(b.Dx()), float64(b.Dy()), color.RGBA{0, 0, 0, 150})
@@ -182,7 +296,38 @@ func (g *App) Draw(screen *ebiten.Image) {
 			"Hide [F10]",
 		)
 		g.scrp.Printf("Output type [F8]: %v", g.settings.OutputType)
-		g.scrp.Println("\n\n\n")
+		g.scrp.PrintColumn(
+			fmt.Sprintf("Scale [F6][F7]: %v", g.settings.Scale),
+			fmt.Sprintf("Cursor [F2]: %v", g.settings.Cursor),
+		)
+		g.scrp.PrintColumn(
+			fmt.Sprintf("Show keys [F3]: %v", g.keystrokesInfo()),
+			fmt.Sprintf("Timestamp [F4]: %v", g.settings.Stamp.Show),
+		)
+		g.scrp.PrintColumn(
+			fmt.Sprintf("Redactions [R]: %v", len(g.settings.Redactions)),
+			"Select region [S]",
+		)
+		g.scrp.PrintColumn(
+			fmt.Sprintf("Presets [1-9]: %v", g.presetList()),
+			fmt.Sprintf("Size lock [F11]: %v", g.settings.SizeLock),
+		)
+		g.scrp.PrintColumn(
+			fmt.Sprintf("Display [F1]: %v", g.displayInfo()),
+			fmt.Sprintf("Follow window [W]: %v", g.followInfo()),
+		)
+		g.scrp.Printf("Tracking [P]: %v", g.settings.Tracking)
+		g.scrp.Println("\n\n")
+	}
+
+	recording := g.capturer != nil && g.capturer.IsRunning()
+	if !g.borderOnly && !recording {
+		drawRedactions(screen, g)
+	}
+
+	if g.redactEditor.Active {
+		g.redactEditor.Draw(screen, g)
+		return
 	}
 
 	if g.capturer != nil {
@@ -192,6 +337,13 @@ func (g *App) Draw(screen *ebiten.Image) {
 }
 
 func (g *App) Layout(outsideWidth, outsideHeight int) (screenWidth, screenHeight int) {
+	if g.regionSelector.Active {
+		// fullscreen, keep the previous window rect
+		return outsideWidth, outsideHeight
+	}
+
+	g.constrainWindowSize(outsideWidth, outsideHeight)
+
 	wr := &g.settings.WindowRect
 	x, y := ebiten.WindowPosition()
 
@@ -225,6 +377,10 @@ func (g *App) Init() {
 	g.loadSettings()
 	g.updateWindowTitle()
 	g.setOutputType(g.settings.OutputType)
+	g.recoverPendingGifs()
+
+	g.applyOptions()
+	g.updateDisplays()
 
 	wr := g.settings.WindowRect
 	ebiten.SetWindowPosition(wr.X, wr.Y)
@@ -237,6 +393,9 @@ func (g *App) Init() {
 	g.scrp.Color = color.White
 	g.scrp.LineSpacing = 10
 
+	g.framePrint.Border = 20
+	g.framePrint.LineSpacing = 10
+
 }
 
 func (g *App) loadSettings() {
@@ -252,7 +411,15 @@ func (g *App) loadSettings() {
 			W: w,
 			H: h,
 		},
-		FrameRate: defaultFrameRate,
+		FrameRate:  defaultFrameRate,
+		Timelapse:  defaultTimelapse,
+		Motion:     defaultMotion,
+		Replay:     defaultReplay,
+		Schedule:   defaultSchedule,
+		Trim:       defaultTrim,
+		Keystrokes: defaultKeystrokes,
+		Stamp:      defaultStamp,
+		Logo:       defaultLogo,
 	}
 
 	g.outputFilename = g.settings.OutputFilename
@@ -285,6 +452,7 @@ func (g *App) loadSettings() {
 		}
 		g.settings.OutputFilename = filename
 	}
+	g.settings.replayOnly()
 
 	g.outputFilename = g.settings.OutputFilename
 }
@@ -326,12 +494,55 @@ func (g *App) parseArgs() {
 			g.autoStart = true
 		case "exit-on-finish":
 			g.exitOnFinish = true
+		case "preset":
+			g.presetName = val
+		case "preset-name":
+			g.presetSaveName = val
+		case "display":
+			g.displayOption = val
+		case "straddle":
+			g.straddleOption = val
+		case "follow":
+			g.followOption = val
 		default:
 			fmt.Printf("unknown option: %v\n", opt)
 		}
 	}
 }
 
+// applyOptions applies the command line options
+// that override the loaded settings.
+func (g *App) applyOptions() {
+	if g.presetName != "" {
+		if preset, ok := FindPreset(g.settings.Presets, g.presetName); ok {
+			g.settings.WindowRect = preset.Rect
+		} else {
+			g.setError(fmt.Errorf("preset not found: %v", g.presetName))
+		}
+	}
+	if g.displayOption != "" {
+		n, err := strconv.Atoi(g.displayOption)
+		if err != nil || n < 0 {
+			g.setError(fmt.Errorf("invalid display: %v", g.displayOption))
+		} else {
+			g.settings.Display.Capture = n
+		}
+	}
+	if g.straddleOption != "" {
+		mode, err := ParseStraddleMode(g.straddleOption)
+		if err != nil {
+			g.setError(err)
+		} else {
+			g.settings.Display.Straddle = mode
+		}
+	}
+	if g.followOption != "" {
+		if err := g.followWindow(g.followOption); err != nil {
+			g.setError(fmt.Errorf("follow %v: %w", g.followOption, err))
+		}
+	}
+}
+
 func (g *App) saveSettings() {
 	file, err := os.OpenFile(g.settingFilename, os.O_WRONLY|os.O_CREATE, 0644)
 	if err != nil {
@@ -381,6 +592,31 @@ func (g *App) loadFonts() {
 	g.regularFont = regularFont
 	g.smallFont = smallFont
 	g.tinyFont = tinyFont
+
+	g.font = tt
+	g.fontFaces = map[float64]font.Face{
+		24: regularFont,
+		18: smallFont,
+		15: tinyFont,
+	}
+}
+
+// fontFace returns the overlay font in the given size.
+func (g *App) fontFace(size float64) font.Face {
+	if face, ok := g.fontFaces[size]; ok {
+		return face
+	}
+	face, err := opentype.NewFace(g.font, &opentype.FaceOptions{
+		Size:    size,
+		DPI:     72,
+		Hinting: font.HintingFull,
+	})
+	if err != nil {
+		g.logError(err)
+		return g.regularFont
+	}
+	g.fontFaces[size] = face
+	return face
 }
 
 func (g *App) setError(err error) {
@@ -402,20 +638,113 @@ func (g *App) updateWindowTitle() {
 	ebiten.SetWindowTitle(fmt.Sprintf("%v %vx%v", title, wr.W, wr.H))
 }
 
-func (g *App) getNextOutFilename() (string, int) {
-	outputFilename := g.settings.OutputFilename
-	if g.settings.OutputMethod != OutputMethodNewFile {
-		return outputFilename, 0
+func (g *App) createOutputFile() (*os.File, int, error) {
+	s := &g.settings
+	tmpl := NewOutputTemplate(s.OutputFilename, s.OutputType, GetWindowBounds())
+	return CreateOutputFile(tmpl, s.OutputMethod)
+}
+
+// createPendingOutput returns a temporary file that replaces
+// the next output file when the recording is committed.
+func (g *App) createPendingOutput() (*PendingFile, error) {
+	s := &g.settings
+	tmpl := NewOutputTemplate(s.OutputFilename, s.OutputType, GetWindowBounds())
+
+	if s.OutputMethod != OutputMethodNewFile {
+		filename, _, err := OverwriteFilename(tmpl)
+		if err != nil {
+			return nil, err
+		}
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			return nil, err
+		}
+		output, err := CreatePendingFile(filename, false)
+		if err == nil {
+			g.rememberOutputDir(filename)
+		}
+		return output, err
 	}
-	_, err := os.Stat(outputFilename)
+
+	file, _, err := CreateOutputFile(tmpl, s.OutputMethod)
 	if err != nil {
-		if !errors.Is(err, fs.ErrNotExist) {
-			g.logError(err)
+		return nil, err
+	}
+	file.Close()
+
+	output, err := CreatePendingFile(file.Name(), true)
+	if err != nil {
+		os.Remove(file.Name())
+		return nil, err
+	}
+	g.rememberOutputDir(file.Name())
+	return output, nil
+}
+
+// rememberOutputDir keeps the directory of filename in the recent
+// output directories, where interrupted recordings are looked for.
+func (g *App) rememberOutputDir(filename string) {
+	dir, err := filepath.Abs(filepath.Dir(filename))
+	if err != nil {
+		return
+	}
+	s := &g.settings
+	if len(s.RecentDirs) > 0 && s.RecentDirs[0] == dir {
+		return
+	}
+	s.RecentDirs = addRecentDir(s.RecentDirs, dir)
+	g.scheduleSaveSettings()
+}
+
+const maxRecentDirs = 10
+
+// addRecentDir returns dirs with dir moved to the front,
+// keeping at most maxRecentDirs.
+func addRecentDir(dirs []string, dir string) []string {
+	result := []string{dir}
+	for _, d := range dirs {
+		if d != dir && len(result) < maxRecentDirs {
+			result = append(result, d)
 		}
-		return outputFilename, 0
+	}
+	return result
+}
+
+// recoverPendingGifs looks for recordings that were interrupted
+// by a crash, and offers to repair them. It looks in the directory
+// of the output file, and in the recent output directories, since
+// the output file may have changed, or have dates in its directory.
+func (g *App) recoverPendingGifs() {
+	s := &g.settings
+	tmpl := NewOutputTemplate(s.OutputFilename, OutputTypeGif, GetWindowBounds())
+	current, err := filepath.Abs(filepath.Dir(tmpl.Filename(0)))
+	if err != nil {
+		current = filepath.Dir(tmpl.Filename(0))
+	}
+	var files []string
+	for _, dir := range addRecentDir(s.RecentDirs, current) {
+		files = append(files, FindPendingGifs(dir)...)
+	}
+	if len(files) == 0 {
+		return
+	}
+
+	ok := dialog.Message(
+		"Found %v interrupted recording(s):\n%v\n\nRepair them now?",
+		len(files), strings.Join(files, "\n"),
+	).Title("screencage").YesNo()
+	if !ok {
+		log.Println("skipped recovery, run `screencage repair` to recover them later")
+		return
 	}
 
-	return NextLatestIncrementedFilename(outputFilename)
+	for _, filename := range files {
+		saved, info, err := RepairGifFile(filename, "")
+		if err != nil {
+			g.logError(fmt.Errorf("%v: %w", filename, err))
+			continue
+		}
+		log.Printf("* recovered %v frames to %v", info.NumFrames, saved)
+	}
 }
 
 func (g *App) logError(err error) {
@@ -426,8 +755,10 @@ func (g *App) logError(err error) {
 func (g *App) setOutputType(outputType OutputType) {
 	s := &g.settings
 	s.OutputType = outputType
-	filename, _ := TrimExt(s.OutputFilename)
-	s.OutputFilename = filename + "." + s.OutputType.String()
+	filename, ext := TrimExt(s.OutputFilename)
+	if ext != ".{ext}" {
+		s.OutputFilename = filename + "." + s.OutputType.String()
+	}
 	g.outputFilename = s.OutputFilename
 
 	switch outputType {
@@ -441,22 +772,19 @@ func (g *App) setOutputType(outputType OutputType) {
 	g.adjustFrameRate()
 }
 
+// adjustFrameRate keeps the frame rate in the range
+// that the output type supports.
 func (g *App) adjustFrameRate() {
 	s := &g.settings
-	unit := g.settings.FrameRate.Unit
 	switch g.settings.OutputType {
 	case OutputTypeGif:
-		if unit != framerate.UnitSecond {
-			s.FrameRate = defaultFrameRate
-		}
-		if s.FrameRate.Value > 30 {
-			s.FrameRate.Value = 30
+		// slow rates only make sense for a timelapse
+		if s.Timelapse.Enabled {
+			s.FrameRate = s.FrameRate.Clamp(framerate.Every(time.Hour), framerate.FPS(30))
+		} else {
+			s.FrameRate = s.FrameRate.Clamp(framerate.FPS(1), framerate.FPS(30))
 		}
 	case OutputTypePng:
-		if s.FrameRate.Value > 30 && s.FrameRate.Unit == framerate.UnitSecond {
-			s.FrameRate.Value = 30
-		} else if s.FrameRate.Value > 60 {
-			s.FrameRate.Value = 60
-		}
+		s.FrameRate = s.FrameRate.Clamp(framerate.Every(time.Hour), framerate.FPS(30))
 	}
 }
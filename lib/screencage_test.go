@@ -1,7 +1,24 @@
 package lib
 
 import (
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strconv"
+	"sync"
 	"testing"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/nvlled/screencage/lib/framerate"
+	"github.com/nvlled/screencage/lib/globalkeys"
+	"github.com/nvlled/screencage/lib/x11win"
 )
 
 func TestIncrementFilename(t *testing.T) {
@@ -112,3 +129,961 @@ func TestQueue(t *testing.T) {
 		t.Errorf("wrong size")
 	}
 }
+
+func TestBoundedQueue(t *testing.T) {
+	q := CreateBoundedQueue[int](3)
+	for i := 1; i <= 5; i++ {
+		q.Push(i)
+	}
+	if q.Size() != 3 {
+		t.Errorf("wrong size, expected=%v, got=%v", 3, q.Size())
+	}
+
+	items := q.Snapshot()
+	if len(items) != 3 || items[0] != 3 || items[1] != 4 || items[2] != 5 {
+		t.Errorf("wrong items, expected=[3 4 5], got=%v", items)
+	}
+	if q.Size() != 3 {
+		t.Errorf("snapshot must not remove items")
+	}
+
+	if val, ok := q.Pop(); !ok || val != 3 {
+		t.Errorf("wrong value, expected=%v, got=%v", 3, val)
+	}
+	q.Push(6)
+	q.Push(7)
+	items = q.Snapshot()
+	if len(items) != 3 || items[0] != 5 || items[2] != 7 {
+		t.Errorf("wrong items, expected=[5 6 7], got=%v", items)
+	}
+}
+
+func TestOutputTemplate(t *testing.T) {
+	dir := t.TempDir()
+	tmpl := OutputTemplate{
+		Template: filepath.Join(dir, "{date}", "{time}-{w}x{h}-{counter:03}.{ext}"),
+		Time:     time.Date(2023, 1, 30, 13, 4, 5, 0, time.UTC),
+		Bounds:   image.Rect(10, 20, 650, 500),
+		Ext:      "gif",
+	}
+
+	expected := filepath.Join(dir, "2023-01-30", "13-04-05-640x480-007.gif")
+	if actual := tmpl.Filename(7); actual != expected {
+		t.Errorf("expected: %v | got %v", expected, actual)
+	}
+
+	if n, err := tmpl.LatestCounter(); n != 0 || err != nil {
+		t.Errorf("wrong counter, expected=%v, got=%v, %v", 0, n, err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "2023-01-30"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, n := range []int{1, 2, 12} {
+		if err := os.WriteFile(tmpl.Filename(n), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n, err := tmpl.LatestCounter(); n != 12 || err != nil {
+		t.Errorf("wrong counter, expected=%v, got=%v, %v", 12, n, err)
+	}
+
+	// the counter in a directory
+	tmpl.Template = filepath.Join(dir, "run-{counter}", "shot.{ext}")
+	for _, n := range []int{3, 9} {
+		if err := os.MkdirAll(filepath.Dir(tmpl.Filename(n)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(tmpl.Filename(n), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n, err := tmpl.LatestCounter(); n != 9 || err != nil {
+		t.Errorf("counter in a directory, expected=%v, got=%v, %v", 9, n, err)
+	}
+	file, counter, err := CreateOutputFile(tmpl, OutputMethodNewFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	if counter != 10 {
+		t.Errorf("counter in a directory, expected the next file to be 10, got=%v", counter)
+	}
+
+	tmpl.Template = filepath.Join(dir, "capture-{w}.{ext}")
+	for _, entry := range []struct {
+		counter  int
+		expected string
+	}{
+		{0, filepath.Join(dir, "capture-640.gif")},
+		{3, filepath.Join(dir, "capture-640-3.gif")},
+	} {
+		if actual := tmpl.Filename(entry.counter); actual != entry.expected {
+			t.Errorf("expected: %v | got %v", entry.expected, actual)
+		}
+	}
+
+	tmpl.Template = filepath.Join(dir, "capture-2.gif")
+	if actual, expected := tmpl.Filename(5), filepath.Join(dir, "capture-5.gif"); actual != expected {
+		t.Errorf("expected: %v | got %v", expected, actual)
+	}
+}
+
+func TestCreateOutputFileConcurrent(t *testing.T) {
+	for _, template := range []string{"capture.gif", "capture-{counter}.gif"} {
+		dir := t.TempDir()
+		tmpl := OutputTemplate{Template: filepath.Join(dir, template), Ext: "gif"}
+
+		const numFiles = 20
+		names := make(chan string, numFiles)
+		var wg sync.WaitGroup
+		for i := 0; i < numFiles; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				file, _, err := CreateOutputFile(tmpl, OutputMethodNewFile)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				defer file.Close()
+				names <- file.Name()
+			}()
+		}
+		wg.Wait()
+		close(names)
+
+		seen := map[string]bool{}
+		for name := range names {
+			if seen[name] {
+				t.Errorf("filename allocated twice: %v", name)
+			}
+			seen[name] = true
+		}
+		if len(seen) != numFiles {
+			t.Errorf("wrong number of files, expected=%v, got=%v", numFiles, len(seen))
+		}
+	}
+}
+
+func TestCreateNextIncrementedFile(t *testing.T) {
+	dir := t.TempDir()
+	first := filepath.Join(dir, "capture-1.gif")
+	if err := os.WriteFile(first, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	const numFiles = 10
+	names := make(chan string, numFiles)
+	var wg sync.WaitGroup
+	for i := 0; i < numFiles; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			file, err := CreateNextIncrementedFile(first)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			defer file.Close()
+			names <- file.Name()
+		}()
+	}
+	wg.Wait()
+	close(names)
+
+	seen := map[string]bool{first: true}
+	for name := range names {
+		if seen[name] {
+			t.Errorf("filename allocated twice: %v", name)
+		}
+		seen[name] = true
+	}
+	for i := 2; i <= numFiles+1; i++ {
+		if name := filepath.Join(dir, "capture-"+strconv.Itoa(i)+".gif"); !seen[name] {
+			t.Errorf("expected %v to be created", name)
+		}
+	}
+}
+
+func TestCreateOutputFileOverwrite(t *testing.T) {
+	dir := t.TempDir()
+	tmpl := OutputTemplate{Template: filepath.Join(dir, "capture.gif"), Ext: "gif"}
+	filename := tmpl.Filename(0)
+	if err := os.WriteFile(filename, []byte("stale contents that are longer"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	file, _, err := CreateOutputFile(tmpl, OutputMethodOverwrite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.WriteString("new")
+	file.Close()
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "new" {
+		t.Errorf("file not truncated: %q", data)
+	}
+}
+
+func TestPendingFile(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "capture.gif")
+	if err := os.WriteFile(target, []byte("previous"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// discarding leaves the target as it was
+	pf, err := CreatePendingFile(target, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pf.WriteString("discarded")
+	if err := pf.Discard(); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(target); string(data) != "previous" {
+		t.Errorf("target changed by Discard: %q", data)
+	}
+	if _, err := os.Stat(pf.Name()); !os.IsNotExist(err) {
+		t.Errorf("temp file not removed: %v", pf.Name())
+	}
+
+	// committing replaces the target, with the usual mode
+	pf, err = CreatePendingFile(target, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pf.WriteString("committed")
+	if err := pf.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(target); string(data) != "committed" {
+		t.Errorf("target not replaced by Commit: %q", data)
+	}
+	info, err := os.Stat(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode := fs.FileMode(0644 &^ umask); runtime.GOOS != "windows" && info.Mode().Perm() != mode {
+		t.Errorf("wrong mode, expected=%v, got=%v", mode, info.Mode().Perm())
+	}
+
+	// salvaging keeps the target and saves a partial file
+	pf, err = CreatePendingFile(target, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pf.WriteString("partial")
+	filename, err := pf.Salvage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := filepath.Join(dir, "capture.partial.gif"); filename != expected {
+		t.Errorf("expected=%v, got=%v", expected, filename)
+	}
+	if data, _ := os.ReadFile(filename); string(data) != "partial" {
+		t.Errorf("wrong partial contents: %q", data)
+	}
+	if data, _ := os.ReadFile(target); string(data) != "committed" {
+		t.Errorf("target changed by Salvage: %q", data)
+	}
+}
+
+func TestAddRecentDir(t *testing.T) {
+	dirs := addRecentDir(nil, "a")
+	dirs = addRecentDir(dirs, "b")
+	dirs = addRecentDir(dirs, "a")
+	if len(dirs) != 2 || dirs[0] != "a" || dirs[1] != "b" {
+		t.Errorf("expected=[a b], got=%v", dirs)
+	}
+
+	for i := 0; i < maxRecentDirs*2; i++ {
+		dirs = addRecentDir(dirs, strconv.Itoa(i))
+	}
+	if len(dirs) != maxRecentDirs || dirs[0] != strconv.Itoa(maxRecentDirs*2-1) {
+		t.Errorf("expected %v dirs, newest first, got=%v", maxRecentDirs, dirs)
+	}
+}
+
+func TestScaledSize(t *testing.T) {
+	for _, entry := range []struct {
+		settings ScaleSettings
+		w, h     int
+	}{
+		{ScaleSettings{}, 2560, 1440},
+		{ScaleSettings{Percent: 100}, 2560, 1440},
+		{ScaleSettings{Percent: 50}, 1280, 720},
+		{ScaleSettings{MaxWidth: 640}, 640, 360},
+		{ScaleSettings{MaxHeight: 360}, 640, 360},
+		{ScaleSettings{Percent: 50, MaxWidth: 1920}, 1280, 720},
+		{ScaleSettings{MaxWidth: 1000, MaxHeight: 360}, 640, 360},
+	} {
+		w, h := entry.settings.ScaledSize(2560, 1440)
+		if w != entry.w || h != entry.h {
+			t.Errorf("%+v: expected=%vx%v, got=%vx%v", entry.settings, entry.w, entry.h, w, h)
+		}
+	}
+}
+
+func TestApplyRedactions(t *testing.T) {
+	for mode := RedactMode(0); mode < RedactMode_Size; mode++ {
+		img := image.NewRGBA(image.Rect(10, 10, 60, 60))
+		for i := range img.Pix {
+			img.Pix[i] = uint8(i * 7)
+		}
+		orig := append([]uint8(nil), img.Pix...)
+
+		rect := Rect{X: 5, Y: 5, W: 20, H: 20}
+		ApplyRedactions(img, []Redaction{{Rect: rect, Mode: mode}}, image.Point{})
+
+		for y := 10; y < 60; y++ {
+			for x := 10; x < 60; x++ {
+				i := img.PixOffset(x, y)
+				inside := image.Pt(x-10, y-10).In(rect.Rectangle())
+				changed := img.Pix[i] != orig[i] || img.Pix[i+1] != orig[i+1] || img.Pix[i+2] != orig[i+2]
+				if !inside && changed {
+					t.Fatalf("%v: pixel outside the redaction changed at %v,%v", mode, x, y)
+				}
+			}
+		}
+
+		same := 0
+		for y := 15; y < 35; y++ {
+			for x := 15; x < 35; x++ {
+				i := img.PixOffset(x, y)
+				if img.Pix[i] == orig[i] && img.Pix[i+1] == orig[i+1] && img.Pix[i+2] == orig[i+2] {
+					same++
+				}
+			}
+		}
+		if same > 40 {
+			t.Errorf("%v: %v pixels of the redaction are unchanged", mode, same)
+		}
+	}
+}
+
+func TestApplyRedactionsOffset(t *testing.T) {
+	rect := Rect{X: 5, Y: 5, W: 20, H: 10}
+	for _, entry := range []struct {
+		offset   image.Point
+		redacted image.Rectangle
+	}{
+		// the cage inside a whole display capture
+		{image.Pt(30, 40), image.Rect(45, 55, 60, 60)},
+		// the cage clipped on the left and top
+		{image.Pt(-10, -8), image.Rect(10, 10, 25, 17)},
+		{image.Pt(100, 0), image.Rectangle{}},
+	} {
+		img := image.NewRGBA(image.Rect(10, 10, 60, 60))
+		draw.Draw(img, img.Rect, image.White, image.Point{}, draw.Src)
+		ApplyRedactions(img, []Redaction{{Rect: rect, Mode: RedactBlack}}, entry.offset)
+
+		for y := img.Rect.Min.Y; y < img.Rect.Max.Y; y++ {
+			for x := img.Rect.Min.X; x < img.Rect.Max.X; x++ {
+				redacted := img.RGBAAt(x, y).R == 0
+				if redacted != image.Pt(x, y).In(entry.redacted) {
+					t.Fatalf("offset %v: expected %v redacted, got pixel %v,%v redacted=%v", entry.offset, entry.redacted, x, y, redacted)
+				}
+			}
+		}
+	}
+}
+
+func TestFixedTrackingRedactions(t *testing.T) {
+	redactions := []Redaction{{Rect: Rect{X: 10, Y: 10, W: 20, H: 10}, Mode: RedactBlack}}
+	redacted := func(img *image.RGBA) []image.Point {
+		var points []image.Point
+		for y := img.Rect.Min.Y; y < img.Rect.Max.Y; y++ {
+			for x := img.Rect.Min.X; x < img.Rect.Max.X; x++ {
+				if img.RGBAAt(x, y).R == 0 {
+					points = append(points, image.Pt(x, y))
+				}
+			}
+		}
+		return points
+	}
+
+	g := &App{}
+	g.settings.Tracking = TrackingFixed
+	first := frameArea{
+		Bounds: image.Rect(100, 100, 200, 200),
+		Cage:   image.Rect(100, 100, 200, 200),
+	}
+	var expected []image.Point
+	for i, cage := range []image.Rectangle{
+		first.Cage,
+		// the cage moved while recording
+		first.Cage.Add(image.Pt(40, 25)),
+		first.Cage.Add(image.Pt(-30, 60)),
+	} {
+		area := g.trackArea(frameArea{Bounds: cage, Cage: cage})
+		g.frameCount++
+		if area != first {
+			t.Errorf("frame %v: expected the area of the first frame, got=%+v", i, area)
+		}
+
+		img := image.NewRGBA(image.Rectangle{Max: area.Bounds.Size()})
+		draw.Draw(img, img.Rect, image.White, image.Point{}, draw.Src)
+		ApplyRedactions(img, redactions, area.redactionOffset())
+		points := redacted(img)
+		if i == 0 {
+			expected = points
+			if len(expected) != 200 {
+				t.Fatalf("expected 200 redacted pixels, got=%v", len(expected))
+			}
+			continue
+		}
+		if !reflect.DeepEqual(points, expected) {
+			t.Errorf("frame %v: the redaction moved in the frame", i)
+		}
+	}
+}
+
+func TestLogoPlacement(t *testing.T) {
+	bounds := image.Rect(100, 50, 400, 250)
+	size := image.Pt(30, 20)
+	for _, entry := range []struct {
+		corner Corner
+		min    image.Point
+	}{
+		{CornerTopLeft, image.Pt(110, 60)},
+		{CornerTopRight, image.Pt(360, 60)},
+		{CornerBottomLeft, image.Pt(110, 220)},
+		{CornerBottomRight, image.Pt(360, 220)},
+	} {
+		r := logoRect(bounds, size, entry.corner)
+		if r.Min != entry.min || r.Size() != size {
+			t.Errorf("%v: expected=%v, got=%v", entry.corner, image.Rectangle{entry.min, entry.min.Add(size)}, r)
+		}
+	}
+
+	for _, entry := range []struct {
+		opacity float64
+		alpha   uint8
+	}{
+		{-1, 0}, {0, 0}, {0.5, 128}, {1, 255}, {2, 255},
+	} {
+		if alpha := logoAlpha(entry.opacity); alpha != entry.alpha {
+			t.Errorf("opacity %v: expected=%v, got=%v", entry.opacity, entry.alpha, alpha)
+		}
+	}
+
+	white := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	draw.Draw(white, white.Bounds(), image.White, image.Point{}, draw.Src)
+	for _, entry := range []struct {
+		opacity float64
+		gray    uint8
+	}{
+		{0, 0}, {0.5, 128}, {1, 255},
+	} {
+		img := image.NewRGBA(image.Rect(0, 0, 40, 40))
+		logo := &Logo{settings: LogoSettings{Corner: CornerTopLeft, Opacity: entry.opacity}, Image: white}
+		logo.Draw(img)
+		if c := img.RGBAAt(logoMargin, logoMargin); c.R != entry.gray {
+			t.Errorf("opacity %v: expected=%v, got=%v", entry.opacity, entry.gray, c.R)
+		}
+		if c := img.RGBAAt(logoMargin-1, logoMargin); c.R != 0 {
+			t.Errorf("opacity %v: drawn outside the logo", entry.opacity)
+		}
+	}
+}
+
+func TestLogoPalette(t *testing.T) {
+	red := color.RGBA{255, 0, 0, 255}
+	blue := color.RGBA{0, 0, 128, 128}
+	img := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	draw.Draw(img, image.Rect(0, 0, 4, 2), image.NewUniform(red), image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(0, 2, 4, 4), image.NewUniform(blue), image.Point{}, draw.Src)
+
+	pal := logoPalette(img)
+	if len(pal) == 0 {
+		t.Fatal("expected the opaque color in the palette")
+	}
+	for _, c := range pal {
+		if c == color.Color(blue) {
+			t.Errorf("translucent color in the palette: %v", pal)
+		}
+	}
+	if pal[0] != color.Color(red) {
+		t.Errorf("expected=%v, got=%v", red, pal)
+	}
+
+	draw.Draw(img, img.Rect, image.NewUniform(blue), image.Point{}, draw.Src)
+	if pal := logoPalette(img); pal != nil {
+		t.Errorf("expected no palette for a translucent logo, got=%v", pal)
+	}
+}
+
+func TestFindPreset(t *testing.T) {
+	presets := []Preset{
+		{Name: "terminal", Rect: Rect{0, 0, 800, 600}},
+		{},
+		{Name: "browser", Rect: Rect{100, 0, 1280, 720}},
+	}
+	for _, entry := range []struct {
+		name  string
+		found bool
+		w     int
+	}{
+		{"terminal", true, 800},
+		{"Browser", true, 1280},
+		{"1", true, 800},
+		{"3", true, 1280},
+		{"2", false, 0},
+		{"4", false, 0},
+		{"editor", false, 0},
+	} {
+		preset, ok := FindPreset(presets, entry.name)
+		if ok != entry.found || preset.Rect.W != entry.w {
+			t.Errorf("%v: expected=%v %v, got=%v %v", entry.name, entry.found, entry.w, ok, preset.Rect.W)
+		}
+	}
+}
+
+func TestSavePreset(t *testing.T) {
+	g := &App{presetSaveName: "terminal"}
+	g.settings.WindowRect = Rect{X: 10, Y: 20, W: 640, H: 480}
+	g.savePreset(2)
+	if preset, ok := FindPreset(g.settings.Presets, "terminal"); !ok || preset.Rect != g.settings.WindowRect {
+		t.Errorf("named preset not saved: %+v", g.settings.Presets)
+	}
+
+	// the name is used once, saving again keeps it
+	g.settings.WindowRect.W = 800
+	g.savePreset(2)
+	g.savePreset(0)
+	if name := g.settings.Presets[2].Name; name != "terminal" {
+		t.Errorf("expected=terminal, got=%v", name)
+	}
+	if name := g.settings.Presets[0].Name; name != "preset 1" {
+		t.Errorf("expected=preset 1, got=%v", name)
+	}
+	if w := g.settings.Presets[2].Rect.W; w != 800 {
+		t.Errorf("expected the preset to be updated, got w=%v", w)
+	}
+}
+
+type fakeConn struct {
+	x11win.Conn
+	closed bool
+}
+
+func (c *fakeConn) Close() { c.closed = true }
+
+func TestFollowRedacted(t *testing.T) {
+	g := &App{}
+	g.settings.Redactions = []Redaction{{Rect: Rect{W: 10, H: 10}}}
+	if err := g.followWindow("firefox"); !errors.Is(err, errFollowRedacted) {
+		t.Errorf("expected errFollowRedacted, got=%v", err)
+	}
+	g.toggleFollow()
+	if g.followPick != nil || !errors.Is(g.followErr, errFollowRedacted) {
+		t.Errorf("started picking a window with redactions set, err=%v", g.followErr)
+	}
+
+	// redactions added while following
+	conn := &fakeConn{}
+	g.followErr = nil
+	g.follow = &followState{conn: conn}
+	g.updateFollow()
+	if g.follow != nil || !conn.closed || !errors.Is(g.followErr, errFollowRedacted) {
+		t.Errorf("still following with redactions set, err=%v", g.followErr)
+	}
+}
+
+func TestSizeLockConstrain(t *testing.T) {
+	for _, entry := range []struct {
+		lock         SizeLock
+		w, h         int
+		prevW, prevH int
+		expW, expH   int
+	}{
+		{SizeLock{}, 500, 400, 500, 400, 500, 400},
+		{SizeLock{SizeLockExact, 1280, 720}, 500, 400, 500, 400, 1280, 720},
+		{SizeLock{SizeLockAspect, 16, 9}, 1600, 400, 1000, 400, 1600, 900},
+		{SizeLock{SizeLockAspect, 16, 9}, 640, 720, 640, 360, 1280, 720},
+		{SizeLock{SizeLockAspect, 4, 3}, 101, 10, 101, 10, 101, 76},
+		{SizeLock{SizeLockAspect, 1, 1}, 0, 0, 5, 5, 1, 1},
+	} {
+		w, h := entry.lock.Constrain(entry.w, entry.h, entry.prevW, entry.prevH)
+		if w != entry.expW || h != entry.expH {
+			t.Errorf("%v %vx%v: expected=%vx%v, got=%vx%v", entry.lock, entry.w, entry.h, entry.expW, entry.expH, w, h)
+		}
+	}
+}
+
+func TestSizeLockWindowSize(t *testing.T) {
+	inset := captureInset * 2
+	for _, entry := range []struct {
+		lock   SizeLock
+		w, h   int
+		scale  float64
+		resize bool
+	}{
+		{SizeLock{SizeLockExact, 1280, 720}, 1280 + inset, 720 + inset, 1, false},
+		{SizeLock{SizeLockExact, 1280, 720}, 500, 400, 1, true},
+		{SizeLock{SizeLockExact, 1279, 719}, 853 + inset, 479 + inset, 1.5, false},
+		{SizeLock{SizeLockExact, 1280, 720}, 500, 400, 1.25, true},
+		{SizeLock{SizeLockAspect, 16, 9}, 500, 400, 1.75, true},
+	} {
+		_, resize := entry.lock.WindowSize(entry.w, entry.h, entry.w, entry.h, entry.scale)
+		if resize != entry.resize {
+			t.Errorf("%v %vx%v@%v: expected resize=%v, got=%v", entry.lock, entry.w, entry.h, entry.scale, entry.resize, resize)
+		}
+	}
+
+	// resizing, like Layout does, must settle
+	for _, lock := range sizeLockPresets[1:] {
+		for _, scale := range []float64{1, 1.25, 1.5, 1.75, 2.25} {
+			prev := image.Pt(500, 400)
+			size := image.Pt(733, 411)
+			n := 0
+			for ; n < 5; n++ {
+				next, resize := lock.WindowSize(size.X, size.Y, prev.X, prev.Y, scale)
+				if !resize {
+					break
+				}
+				prev, size = size, next
+			}
+			if n == 5 {
+				t.Errorf("%v@%v: still resizing at %v", lock, scale, size)
+			}
+		}
+	}
+}
+
+func TestCaptureBounds(t *testing.T) {
+	for _, entry := range []struct {
+		x, y, w, h int
+		scale      float64
+		expected   image.Rectangle
+	}{
+		{0, 0, 646, 486, 1, image.Rect(3, 3, 643, 483)},
+		{100, 50, 646, 486, 1, image.Rect(103, 53, 743, 533)},
+		{100, 50, 646, 486, 2, image.Rect(206, 106, 1486, 1066)},
+		{100, 50, 646, 486, 1.5, image.Rect(155, 80, 1115, 800)},
+		{10, 10, 106, 106, 1.25, image.Rect(16, 16, 141, 141)},
+	} {
+		r := captureBounds(entry.x, entry.y, entry.w, entry.h, entry.scale)
+		if r != entry.expected {
+			t.Errorf("%v,%v %vx%v at %v: expected=%v, got=%v", entry.x, entry.y, entry.w, entry.h, entry.scale, entry.expected, r)
+		}
+	}
+
+	for _, scale := range []float64{1, 1.25, 1.5, 2} {
+		for _, p := range []image.Point{{0, 0}, {100, 40}, {639, 479}} {
+			window := captureToWindow(p, scale)
+			back := windowToCapture(window, scale)
+			if d := back.Sub(p); d.X*d.X > 1 || d.Y*d.Y > 1 {
+				t.Errorf("%v at %v: mapped back to %v", p, scale, back)
+			}
+		}
+		bounds := captureBounds(0, 0, 646, 486, scale)
+		if p := windowToCapture(image.Pt(captureInset, captureInset), scale); p != (image.Point{}) {
+			t.Errorf("window inset at %v: expected=(0,0), got=%v", scale, p)
+		}
+		if p := windowToCapture(image.Pt(646-captureInset, 486-captureInset), scale); p != bounds.Size() {
+			t.Errorf("window corner at %v: expected=%v, got=%v", scale, bounds.Size(), p)
+		}
+	}
+}
+
+func TestFindDisplay(t *testing.T) {
+	displays := []image.Rectangle{
+		image.Rect(0, 0, 1920, 1080),
+		image.Rect(1920, 0, 4480, 1440),
+		image.Rect(4480, 0, 6400, 1080),
+	}
+	for _, entry := range []struct {
+		window   image.Rectangle
+		size     image.Point
+		prev     int
+		expected int
+	}{
+		{image.Rectangle{}, image.Pt(1920, 1080), 0, 0},
+		{image.Rectangle{}, image.Pt(1920, 1080), 2, 2},
+		{image.Rectangle{}, image.Pt(1920, 1080), 1, 0},
+		{image.Rectangle{}, image.Pt(2560, 1440), 0, 1},
+		{image.Rectangle{}, image.Pt(800, 600), 2, 2},
+		{image.Rectangle{}, image.Pt(800, 600), 5, 0},
+
+		// same size as display 0, but on display 2
+		{image.Rect(5000, 100, 5800, 700), image.Pt(1920, 1080), 0, 2},
+		// straddling, mostly on display 1
+		{image.Rect(1700, 100, 2500, 700), image.Pt(1920, 1080), 0, 1},
+		{image.Rect(4000, 100, 4800, 700), image.Pt(2560, 1440), 1, 1},
+		// off the displays, falls back to the size
+		{image.Rect(0, 2000, 800, 2600), image.Pt(2560, 1440), 0, 1},
+	} {
+		if i := findDisplay(displays, entry.window, entry.size, entry.prev); i != entry.expected {
+			t.Errorf("%v %v, prev=%v: expected=%v, got=%v", entry.window, entry.size, entry.prev, entry.expected, i)
+		}
+	}
+}
+
+func TestStitchParts(t *testing.T) {
+	displays := []image.Rectangle{
+		image.Rect(0, 0, 1920, 1080),
+		image.Rect(1920, 0, 4480, 1440),
+	}
+	parts := stitchParts(image.Rect(1800, 1000, 2000, 1200), displays)
+	expected := []image.Rectangle{
+		image.Rect(1800, 1000, 1920, 1080),
+		image.Rect(1920, 1000, 2000, 1200),
+	}
+	if len(parts) != len(expected) {
+		t.Fatalf("expected=%v, got=%v", expected, parts)
+	}
+	for i := range parts {
+		if parts[i] != expected[i] {
+			t.Errorf("expected=%v, got=%v", expected, parts)
+		}
+	}
+
+	if parts := stitchParts(image.Rect(10, 10, 100, 100), displays); len(parts) != 1 {
+		t.Errorf("expected one part, got=%v", parts)
+	}
+}
+
+func TestNormalizeFrame(t *testing.T) {
+	img := image.NewRGBA(image.Rect(100, 100, 140, 120))
+	for i := range img.Pix {
+		img.Pix[i] = 200
+	}
+
+	if normalizeFrame(img, image.Pt(40, 20)) != img {
+		t.Error("frame with the same size was copied")
+	}
+
+	padded := normalizeFrame(img, image.Pt(50, 30))
+	if padded.Rect != image.Rect(0, 0, 50, 30) {
+		t.Fatalf("expected=%v, got=%v", image.Rect(0, 0, 50, 30), padded.Rect)
+	}
+	if c := padded.RGBAAt(39, 19); c.R != 200 {
+		t.Errorf("expected the frame at the top left, got=%v", c)
+	}
+	if c := padded.RGBAAt(45, 25); c != ColorBlack {
+		t.Errorf("expected black padding, got=%v", c)
+	}
+
+	cropped := normalizeFrame(img, image.Pt(30, 10))
+	if cropped.Rect != image.Rect(0, 0, 30, 10) || cropped.RGBAAt(29, 9).R != 200 {
+		t.Errorf("bad crop: %v", cropped.Rect)
+	}
+}
+
+func TestTimelapse(t *testing.T) {
+	everyMinute := framerate.Every(time.Minute)
+	s := TimelapseSettings{Enabled: true, PlaybackRate: framerate.FPS(10)}
+
+	if at := s.PlaybackTime(30*time.Minute, everyMinute); at != 3*time.Second {
+		t.Errorf("expected=3s, got=%v", at)
+	}
+	if d := s.OutputDuration(time.Hour, everyMinute); d != 6*time.Second {
+		t.Errorf("expected=6s, got=%v", d)
+	}
+
+	s.Enabled = false
+	if at := s.PlaybackTime(30*time.Minute, everyMinute); at != 30*time.Minute {
+		t.Errorf("disabled: expected=30m, got=%v", at)
+	}
+}
+
+func TestMotionDetector(t *testing.T) {
+	frame := func(changed image.Rectangle) *image.RGBA {
+		img := image.NewRGBA(image.Rect(0, 0, 20, 20))
+		draw.Draw(img, changed, image.NewUniform(color.White), image.Point{}, draw.Src)
+		return img
+	}
+	still := frame(image.Rectangle{})
+
+	d := NewMotionDetector(MotionSettings{
+		Threshold:      5,
+		PixelThreshold: 24,
+		IdleSeconds:    1,
+		IgnoreMasks:    []Rect{{X: 0, Y: 0, W: 10, H: 10}},
+	})
+
+	steps := []struct {
+		img    *image.RGBA
+		at     time.Duration
+		record bool
+		start  bool
+	}{
+		{still, 0, false, false},
+		{still, 100 * time.Millisecond, false, false},
+		// only in the ignored area
+		{frame(image.Rect(0, 0, 10, 10)), 200 * time.Millisecond, false, false},
+		{frame(image.Rect(10, 10, 20, 20)), 300 * time.Millisecond, true, true},
+		// changing back is motion too
+		{still, 400 * time.Millisecond, true, false},
+		{still, 1400 * time.Millisecond, true, false},
+		{still, 1500 * time.Millisecond, false, false},
+		{frame(image.Rect(10, 0, 20, 10)), 1600 * time.Millisecond, true, true},
+	}
+	for i, step := range steps {
+		record, start := d.Update(step.img, step.at)
+		if record != step.record || start != step.start {
+			t.Errorf("step %v: expected=%v,%v got=%v,%v", i, step.record, step.start, record, start)
+		}
+	}
+}
+
+func TestReplayFrames(t *testing.T) {
+	var frames []ReplayFrame
+	for i := 0; i < 10; i++ {
+		frames = append(frames, ReplayFrame{At: time.Duration(i) * time.Second})
+	}
+	// a dropped tick
+	frames[9].At = 10 * time.Second
+
+	last := lastFrames(frames[:9], 3*time.Second)
+	if len(last) != 3 || last[0].At != 6*time.Second {
+		t.Errorf("expected 3 frames from 6s, got=%v", len(last))
+	}
+
+	last = lastFrames(frames, 3*time.Second)
+	if len(last) != 2 || last[0].At != 8*time.Second {
+		t.Errorf("expected 2 frames from 8s, got=%v", len(last))
+	}
+
+	delays := replayDelays(last, time.Second)
+	expected := []int{100, 200}
+	for i := range expected {
+		if delays[i] != expected[i] {
+			t.Errorf("expected=%v, got=%v", expected, delays)
+			break
+		}
+	}
+
+	if last := lastFrames(nil, time.Second); len(last) != 0 {
+		t.Errorf("expected no frames, got=%v", len(last))
+	}
+
+	for _, entry := range []struct {
+		length, interval time.Duration
+		size             image.Point
+		expected         int
+	}{
+		{30 * time.Second, time.Second / 10, image.Pt(640, 480), 301},
+		{60 * time.Second, time.Second / 30, image.Pt(3840, 2160), maxReplayBytes / (3840 * 2160)},
+		{time.Second, time.Second, image.Pt(1<<15, 1<<15), 1},
+	} {
+		if n := replayCapacity(entry.length, entry.interval, entry.size); n != entry.expected {
+			t.Errorf("%v at %v, %v: expected=%v, got=%v", entry.length, entry.interval, entry.size, entry.expected, n)
+		}
+	}
+}
+
+func TestReplaySaveHotkey(t *testing.T) {
+	for _, entry := range []struct {
+		press    globalkeys.Press
+		expected bool
+	}{
+		{globalkeys.Press{Key: ebiten.KeyR, Held: []ebiten.Key{ebiten.KeyControlLeft, ebiten.KeyAltRight, ebiten.KeyR}}, true},
+		{globalkeys.Press{Key: ebiten.KeyR, Held: []ebiten.Key{ebiten.KeyControlRight, ebiten.KeyAltLeft, ebiten.KeyShiftLeft, ebiten.KeyR}}, true},
+		{globalkeys.Press{Key: ebiten.KeyR, Held: []ebiten.Key{ebiten.KeyControlLeft, ebiten.KeyR}}, false},
+		{globalkeys.Press{Key: ebiten.KeySpace, Held: []ebiten.Key{ebiten.KeySpace}}, false},
+		{globalkeys.Press{Key: ebiten.KeyT, Held: []ebiten.Key{ebiten.KeyControlLeft, ebiten.KeyAltLeft, ebiten.KeyT}}, false},
+	} {
+		if save := isReplaySave(entry.press); save != entry.expected {
+			t.Errorf("%v: expected=%v, got=%v", entry.press, entry.expected, save)
+		}
+	}
+}
+
+func TestReplayOnly(t *testing.T) {
+	s := Settings{
+		Timelapse: TimelapseSettings{Enabled: true},
+		Motion:    MotionSettings{Enabled: true},
+	}
+	s.replayOnly()
+	if !s.Timelapse.Enabled || !s.Motion.Enabled {
+		t.Errorf("turned off without replay: %+v %+v", s.Timelapse, s.Motion)
+	}
+	s.Replay.Enabled = true
+	s.replayOnly()
+	if s.Timelapse.Enabled || s.Motion.Enabled {
+		t.Errorf("still on with replay: %+v %+v", s.Timelapse, s.Motion)
+	}
+}
+
+func TestSchedule(t *testing.T) {
+	s := ScheduleSettings{
+		Start: "09:00",
+		Stop:  "17:00",
+		Days:  []string{"mon", "tue", "wed", "thu", "fri"},
+		Rate:  framerate.Every(15 * time.Minute),
+	}
+	sched, err := s.Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	at := func(day, hour, min int) time.Time {
+		// 2023-01-02 is a monday
+		return time.Date(2023, 1, 2+day, hour, min, 0, 0, time.UTC)
+	}
+	tests := []struct {
+		now, expected time.Time
+	}{
+		{at(0, 8, 0), at(0, 9, 0)},
+		{at(0, 9, 0), at(0, 9, 0)},
+		{at(0, 9, 1), at(0, 9, 15)},
+		{at(0, 16, 50), at(1, 9, 0)},
+		// friday evening, to monday
+		{at(4, 17, 0), at(7, 9, 0)},
+		{at(5, 12, 0), at(7, 9, 0)},
+	}
+	for _, test := range tests {
+		if next := sched.Next(test.now); !next.Equal(test.expected) {
+			t.Errorf("%v: expected=%v, got=%v", test.now, test.expected, next)
+		}
+	}
+
+	// overnight, every day
+	s = ScheduleSettings{Start: "22:00", Stop: "02:00", Rate: framerate.Every(time.Hour)}
+	sched, err = s.Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the stop time is left out
+	if next := sched.Next(at(1, 1, 30)); !next.Equal(at(1, 22, 0)) {
+		t.Errorf("overnight: expected=%v, got=%v", at(1, 22, 0), next)
+	}
+	if next := sched.Next(at(1, 0, 30)); !next.Equal(at(1, 1, 0)) {
+		t.Errorf("overnight: expected=%v, got=%v", at(1, 1, 0), next)
+	}
+
+	for _, entry := range []struct {
+		output, expected string
+	}{
+		{"capture.png", "capture-{date}-{time}.{ext}"},
+		{"shots/{date}.png", "shots/{date}-{time}.{ext}"},
+		{"shots/{time}.png", "shots/{time}-{date}.{ext}"},
+		{"{date}/{time}.{ext}", "{date}/{time}.{ext}"},
+		{"shot-{counter:03}.png", "shot-{counter:03}-{date}-{time}.{ext}"},
+		{"{date}/shot-{counter:03}.png", "{date}/shot-{counter:03}-{time}.{ext}"},
+	} {
+		if tmpl := (ScheduleSettings{}).template(entry.output); tmpl != entry.expected {
+			t.Errorf("%v: expected=%v, got=%v", entry.output, entry.expected, tmpl)
+		}
+	}
+	if tmpl := (ScheduleSettings{Filename: "s.png"}).template("capture.png"); tmpl != "s.png" {
+		t.Errorf("the schedule filename is not used: %v", tmpl)
+	}
+
+	for _, invalid := range []ScheduleSettings{
+		{Start: "9am", Stop: "17:00", Rate: framerate.FPS(1)},
+		{Start: "09:00", Stop: "17:00", Days: []string{"someday"}, Rate: framerate.FPS(1)},
+		{Start: "09:00", Stop: "17:00"},
+	} {
+		if _, err := invalid.Parse(); err == nil {
+			t.Errorf("expected an error for %+v", invalid)
+		}
+	}
+}
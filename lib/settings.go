@@ -1,7 +1,10 @@
 package lib
 
 import (
+	"time"
+
 	"github.com/nvlled/screencage/lib/framerate"
+	"github.com/nvlled/screencage/lib/gifedit"
 )
 
 type Settings struct {
@@ -18,7 +21,58 @@ type Settings struct {
 
 	HideOnCapture bool `json:"HideOnCapture"`
 
+	// Save an interrupted recording as *.partial.gif
+	// instead of deleting it.
+	KeepPartialOnError bool `json:"keepPartialOnError"`
+
+	// Like "12fps", "2.5fps" or "1/90s".
 	FrameRate framerate.T
+
+	Timelapse TimelapseSettings `json:"timelapse"`
+
+	// Only record while the capture area changes, gif only.
+	Motion MotionSettings `json:"motion"`
+
+	// Keep the last seconds, and save them on [space], gif only.
+	Replay ReplaySettings `json:"replay"`
+
+	// Screenshots between times of day, png only.
+	Schedule ScheduleSettings `json:"schedule"`
+
+	// Applied to each frame before it's saved.
+	Scale      ScaleSettings     `json:"scale"`
+	Cursor     CursorSettings    `json:"cursor"`
+	Keystrokes KeystrokeSettings `json:"keystrokes"`
+	Stamp      StampSettings     `json:"stamp"`
+	Logo       LogoSettings      `json:"logo"`
+
+	Presets  []Preset        `json:"presets"`
+	SizeLock SizeLock        `json:"sizeLock"`
+	Display  DisplaySettings `json:"display"`
+	Tracking TrackingMode    `json:"tracking"`
+
+	// Hidden in every frame, before anything else is drawn.
+	Redactions []Redaction `json:"redactions"`
+
+	// Used by [t] after a recording is saved.
+	Trim TrimSettings `json:"trim"`
+
+	// Where recordings were saved lately, most recent first.
+	// Interrupted recordings are looked for there on startup.
+	RecentDirs []string `json:"recentDirs"`
+}
+
+// TrimSettings is how much to drop from a saved recording, in seconds.
+type TrimSettings struct {
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+}
+
+func (trim TrimSettings) Options() gifedit.TrimOptions {
+	return gifedit.TrimOptions{
+		Start: time.Duration(trim.Start * float64(time.Second)),
+		End:   time.Duration(trim.End * float64(time.Second)),
+	}
 }
 
 type CaptureRate struct {
@@ -33,7 +87,9 @@ type Rect struct {
 	H int
 }
 
-var defaultFrameRate = framerate.T{Value: 5, Unit: framerate.UnitSecond}
+var defaultFrameRate = framerate.FPS(5)
+
+var defaultTrim = TrimSettings{Start: 1, End: 1}
 
 const (
 	defaultSettingsFile  = "screencage.json"
@@ -0,0 +1,139 @@
+package lib
+
+import (
+	"fmt"
+	"image"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// SizeLock constrains the size of the capture area,
+// which is the window without the border.
+type SizeLock struct {
+	Mode SizeLockMode `json:"mode"`
+
+	// The exact size, or the aspect ratio.
+	W int `json:"w"`
+	H int `json:"h"`
+}
+
+type SizeLockMode int
+
+const (
+	SizeLockOff SizeLockMode = iota
+	SizeLockExact
+	SizeLockAspect
+
+	SizeLockMode_Size
+)
+
+func (mode SizeLockMode) String() string {
+	switch mode {
+	case SizeLockOff:
+		return "off"
+	case SizeLockExact:
+		return "exact"
+	case SizeLockAspect:
+		return "aspect"
+	}
+	return "invalid-size-lock-mode"
+}
+
+// the locks cycled through with [F11]
+var sizeLockPresets = []SizeLock{
+	{},
+	{SizeLockExact, 1280, 720},
+	{SizeLockExact, 1920, 1080},
+	{SizeLockExact, 1080, 1080},
+	{SizeLockAspect, 16, 9},
+	{SizeLockAspect, 4, 3},
+	{SizeLockAspect, 1, 1},
+	{SizeLockAspect, 9, 16},
+}
+
+func (lock SizeLock) String() string {
+	switch lock.Mode {
+	case SizeLockExact:
+		return fmt.Sprintf("%vx%v", lock.W, lock.H)
+	case SizeLockAspect:
+		return fmt.Sprintf("%v:%v", lock.W, lock.H)
+	}
+	return "off"
+}
+
+// Next returns the preset after lock.
+func (lock SizeLock) Next() SizeLock {
+	for i, preset := range sizeLockPresets {
+		if preset == lock {
+			return sizeLockPresets[(i+1)%len(sizeLockPresets)]
+		}
+	}
+	return sizeLockPresets[0]
+}
+
+// Constrain returns the locked size for a capture area resized from
+// prevW x prevH to w x h. With an aspect lock, the dimension that
+// changed the most decides the other one.
+func (lock SizeLock) Constrain(w, h, prevW, prevH int) (int, int) {
+	if lock.W <= 0 || lock.H <= 0 {
+		return w, h
+	}
+
+	switch lock.Mode {
+	case SizeLockExact:
+		return lock.W, lock.H
+	case SizeLockAspect:
+		dw, dh := abs(w-prevW), abs(h-prevH)
+		if dw*lock.H >= dh*lock.W {
+			h = (w*lock.H + lock.W/2) / lock.W
+		} else {
+			w = (h*lock.W + lock.H/2) / lock.H
+		}
+		if w < 1 {
+			w = 1
+		}
+		if h < 1 {
+			h = 1
+		}
+	}
+	return w, h
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// WindowSize returns the window size for a window resized from
+// prevW x prevH to w x h, and false if it doesn't have to be resized.
+// The lock is in physical pixels, the window size isn't. With a
+// fractional scale, not every physical size can be reached, so
+// a pixel off either way counts as matching the lock.
+func (lock SizeLock) WindowSize(w, h, prevW, prevH int, scale float64) (image.Point, bool) {
+	size := captureBounds(0, 0, w, h, scale).Size()
+	prevSize := captureBounds(0, 0, prevW, prevH, scale).Size()
+
+	lockW, lockH := lock.Constrain(size.X, size.Y, prevSize.X, prevSize.Y)
+	if abs(lockW-size.X) <= 1 && abs(lockH-size.Y) <= 1 {
+		return image.Pt(w, h), false
+	}
+	windowSize := scalePoint(image.Pt(lockW, lockH), 1/scale).Add(image.Pt(captureInset*2, captureInset*2))
+	return windowSize, windowSize != image.Pt(w, h)
+}
+
+// constrainWindowSize resizes the window if the size
+// of its capture area doesn't match the size lock.
+func (g *App) constrainWindowSize(outsideWidth, outsideHeight int) {
+	lock := g.settings.SizeLock
+	if lock.Mode == SizeLockOff {
+		return
+	}
+
+	wr := g.settings.WindowRect
+	size, ok := lock.WindowSize(outsideWidth, outsideHeight, wr.W, wr.H, ebiten.DeviceScaleFactor())
+	if ok {
+		ebiten.SetWindowSize(size.X, size.Y)
+	}
+}
@@ -0,0 +1,136 @@
+package lib
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"strconv"
+	"time"
+)
+
+type StampSettings struct {
+	// Draw the stamp on every frame.
+	Show bool `json:"show"`
+
+	// {date}, {time}, {elapsed} and {frame} are replaced
+	// with the capture date and time, the time since the
+	// recording started, and the frame number.
+	Format string `json:"format"`
+
+	Corner   Corner  `json:"corner"`
+	FontSize float64 `json:"fontSize"`
+
+	// Text color, as #rrggbb or #rrggbbaa.
+	Color string `json:"color"`
+
+	// Draw a dark box behind the text.
+	Background bool `json:"background"`
+}
+
+var defaultStamp = StampSettings{
+	Format:     "{date} {time} #{frame}",
+	Corner:     CornerTopRight,
+	FontSize:   15,
+	Color:      "#ffffff",
+	Background: true,
+}
+
+type Corner int
+
+const (
+	CornerTopLeft Corner = iota
+	CornerTopRight
+	CornerBottomLeft
+	CornerBottomRight
+
+	Corner_Size
+)
+
+func (corner Corner) String() string {
+	switch corner {
+	case CornerTopLeft:
+		return "top left"
+	case CornerTopRight:
+		return "top right"
+	case CornerBottomLeft:
+		return "bottom left"
+	case CornerBottomRight:
+		return "bottom right"
+	}
+	return "invalid-corner"
+}
+
+// align returns the corner as a ScreenPrint.PrintAt alignment.
+func (corner Corner) align() byte {
+	switch corner {
+	case CornerTopRight:
+		return 0b0100
+	case CornerBottomLeft:
+		return 0b1001
+	case CornerBottomRight:
+		return 0b0101
+	}
+	return 0b1000
+}
+
+// Text expands the stamp format for a frame.
+func (s StampSettings) Text(frame int, now, start time.Time) string {
+	return expandPlaceholders(s.Format, func(name, _ string) (string, bool) {
+		switch name {
+		case "date":
+			return now.Format("2006-01-02"), true
+		case "time":
+			return now.Format("15:04:05"), true
+		case "elapsed":
+			return formatElapsed(now.Sub(start)), true
+		case "frame":
+			return strconv.Itoa(frame), true
+		}
+		return "", false
+	})
+}
+
+func formatElapsed(d time.Duration) string {
+	d = d.Round(time.Second)
+	h := d / time.Hour
+	m := (d % time.Hour) / time.Minute
+	s := (d % time.Minute) / time.Second
+	return fmt.Sprintf("%02d:%02d:%02d", h, m, s)
+}
+
+// parseHexColor parses #rgb, #rrggbb or #rrggbbaa.
+func parseHexColor(str string) (color.NRGBA, error) {
+	c := color.NRGBA{A: 255}
+	var err error
+	switch len(str) {
+	case 4:
+		_, err = fmt.Sscanf(str, "#%1x%1x%1x", &c.R, &c.G, &c.B)
+		c.R, c.G, c.B = c.R*17, c.G*17, c.B*17
+	case 7:
+		_, err = fmt.Sscanf(str, "#%02x%02x%02x", &c.R, &c.G, &c.B)
+	case 9:
+		_, err = fmt.Sscanf(str, "#%02x%02x%02x%02x", &c.R, &c.G, &c.B, &c.A)
+	default:
+		err = fmt.Errorf("invalid color: %q", str)
+	}
+	return c, err
+}
+
+// drawStamp prints the stamp on a captured frame.
+func (g *App) drawStamp(img *image.RGBA) {
+	s := g.settings.Stamp
+	textColor, err := parseHexColor(s.Color)
+	if err != nil {
+		textColor = color.NRGBA{255, 255, 255, 255}
+	}
+
+	scrp := g.framePrint
+	scrp.ResetFrame(img)
+	scrp.Font = g.fontFace(s.FontSize)
+	scrp.Color = textColor
+	scrp.Background = nil
+	if s.Background {
+		scrp.Background = ColorBlackTransparent
+	}
+	scrp.PrintAt(s.Corner.align(), s.Text(g.frameCount, time.Now(), g.recordStart))
+}
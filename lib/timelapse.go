@@ -0,0 +1,62 @@
+package lib
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/nvlled/screencage/lib/framerate"
+)
+
+type TimelapseSettings struct {
+	// Encode the frames at PlaybackRate,
+	// instead of the real time between them.
+	Enabled      bool        `json:"enabled"`
+	PlaybackRate framerate.T `json:"playbackRate"`
+}
+
+var defaultTimelapse = TimelapseSettings{
+	PlaybackRate: framerate.FPS(10),
+}
+
+// PlaybackTime maps the time of a frame on the
+// capture timeline to its time in the output.
+func (s TimelapseSettings) PlaybackTime(at time.Duration, captureRate framerate.T) time.Duration {
+	interval := captureRate.Duration()
+	if !s.Enabled || interval <= 0 {
+		return at
+	}
+	return time.Duration(int64(at/interval)) * s.PlaybackRate.Duration()
+}
+
+// OutputDuration returns how long a recording of the given length plays.
+func (s TimelapseSettings) OutputDuration(recording time.Duration, captureRate framerate.T) time.Duration {
+	interval := captureRate.Duration()
+	if !s.Enabled || interval <= 0 {
+		return recording
+	}
+	return time.Duration(int64(recording/interval)) * s.PlaybackRate.Duration()
+}
+
+// Describe tells how long a sample recording plays, for the overlay.
+func (s TimelapseSettings) Describe(captureRate framerate.T) string {
+	if !s.Enabled {
+		return "off"
+	}
+	sample := time.Hour
+	if captureRate.FramesPerSecond() >= 1 {
+		sample = time.Minute
+	}
+	output := s.OutputDuration(sample, captureRate).Round(100 * time.Millisecond)
+	return fmt.Sprintf("plays at %v, %v of recording lasts %v",
+		s.PlaybackRate, shortDuration(sample), output)
+}
+
+func shortDuration(d time.Duration) string {
+	switch d {
+	case time.Hour:
+		return "1h"
+	case time.Minute:
+		return "1m"
+	}
+	return d.String()
+}
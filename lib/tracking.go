@@ -0,0 +1,80 @@
+package lib
+
+import (
+	"image"
+	"image/draw"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// TrackingMode is what happens when the cage
+// is moved while recording.
+type TrackingMode int
+
+const (
+	// the capture area moves with the cage, like a panning camera
+	TrackingPan TrackingMode = iota
+	// the capture area stays where the recording started
+	TrackingFixed
+
+	TrackingMode_Size
+)
+
+func (mode TrackingMode) String() string {
+	switch mode {
+	case TrackingPan:
+		return "pan"
+	case TrackingFixed:
+		return "fixed"
+	}
+	return "invalid-tracking-mode"
+}
+
+// normalizeFrame makes img the given size, so that every frame of
+// a recording has the size of the first one. A smaller img is padded
+// with black, a larger one is cropped, keeping the top left corner.
+func normalizeFrame(img *image.RGBA, size image.Point) *image.RGBA {
+	if img.Rect.Size() == size {
+		return img
+	}
+	dst := image.NewRGBA(image.Rectangle{Max: size})
+	draw.Draw(dst, dst.Rect, image.NewUniform(ColorBlack), image.Point{}, draw.Src)
+	draw.Draw(dst, dst.Rect, img, img.Rect.Min, draw.Src)
+	return dst
+}
+
+// trackArea applies the tracking mode to the area about to be
+// captured. When fixed, the cage is kept where the recording started
+// too, so that the redactions stay over what they were drawn on.
+func (g *App) trackArea(area frameArea) frameArea {
+	if g.frameCount == 0 {
+		g.recordArea = area
+	}
+	if g.settings.Tracking == TrackingFixed {
+		return g.recordArea
+	}
+	return area
+}
+
+// panCage moves the cage with the arrow keys while recording.
+func (g *App) panCage() {
+	step := 10
+	if ebiten.IsKeyPressed(ebiten.KeyShift) {
+		step = 50
+	}
+	var delta image.Point
+	switch {
+	case keyRepeated(ebiten.KeyArrowLeft):
+		delta.X = -step
+	case keyRepeated(ebiten.KeyArrowRight):
+		delta.X = step
+	case keyRepeated(ebiten.KeyArrowUp):
+		delta.Y = -step
+	case keyRepeated(ebiten.KeyArrowDown):
+		delta.Y = step
+	}
+	if delta != (image.Point{}) {
+		x, y := ebiten.WindowPosition()
+		ebiten.SetWindowPosition(x+delta.X, y+delta.Y)
+	}
+}
@@ -0,0 +1,6 @@
+//go:build !unix
+
+package lib
+
+// umask is always 0 where there is none.
+var umask uint32
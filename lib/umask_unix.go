@@ -0,0 +1,15 @@
+//go:build unix
+
+package lib
+
+import "syscall"
+
+// umask is read once at startup, since reading it means setting it,
+// which would race with files created by other goroutines later.
+var umask = readUmask()
+
+func readUmask() uint32 {
+	mask := syscall.Umask(0)
+	syscall.Umask(mask)
+	return uint32(mask)
+}
@@ -0,0 +1,49 @@
+//go:build !windows
+
+package lib
+
+import (
+	"image"
+
+	"github.com/nvlled/screencage/lib/x11win"
+)
+
+// ownWindow is the window of this app on X11, found on first use.
+var ownWindow struct {
+	conn   x11win.Conn
+	win    x11win.Window
+	found  bool
+	failed bool
+}
+
+// desktopWindowBounds returns the bounds of the window on the desktop,
+// in physical pixels, or false where that can't be told.
+func desktopWindowBounds() (image.Rectangle, bool) {
+	w := &ownWindow
+	if w.failed {
+		return image.Rectangle{}, false
+	}
+	if w.conn == nil {
+		conn, err := x11win.Connect()
+		if err != nil {
+			w.failed = true
+			return image.Rectangle{}, false
+		}
+		w.conn = conn
+	}
+	if !w.found {
+		// it's not listed until it's mapped, so keep looking
+		win, err := w.conn.Self()
+		if err != nil {
+			return image.Rectangle{}, false
+		}
+		w.win, w.found = win, true
+	}
+
+	r, err := w.conn.Bounds(w.win)
+	if err != nil {
+		w.found = false
+		return image.Rectangle{}, false
+	}
+	return r, true
+}
@@ -0,0 +1,51 @@
+package lib
+
+import (
+	"image"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	user32                       = syscall.NewLazyDLL("user32.dll")
+	procEnumWindows              = user32.NewProc("EnumWindows")
+	procGetWindowThreadProcessId = user32.NewProc("GetWindowThreadProcessId")
+	procIsWindowVisible          = user32.NewProc("IsWindowVisible")
+	procGetWindowRect            = user32.NewProc("GetWindowRect")
+)
+
+// ownWindow is the handle of the window of this app, found on first use.
+var ownWindow uintptr
+
+// callbacks can't be freed, so there is only the one
+var enumOwnWindow = syscall.NewCallback(func(hwnd, pid uintptr) uintptr {
+	var windowPID uint32
+	procGetWindowThreadProcessId.Call(hwnd, uintptr(unsafe.Pointer(&windowPID)))
+	if uintptr(windowPID) != pid {
+		return 1
+	}
+	if visible, _, _ := procIsWindowVisible.Call(hwnd); visible == 0 {
+		return 1
+	}
+	ownWindow = hwnd
+	return 0
+})
+
+// desktopWindowBounds returns the bounds of the window on the desktop,
+// in physical pixels, or false where that can't be told.
+func desktopWindowBounds() (image.Rectangle, bool) {
+	if ownWindow == 0 {
+		procEnumWindows.Call(enumOwnWindow, uintptr(os.Getpid()))
+		if ownWindow == 0 {
+			return image.Rectangle{}, false
+		}
+	}
+
+	var rect struct{ Left, Top, Right, Bottom int32 }
+	if ok, _, _ := procGetWindowRect.Call(ownWindow, uintptr(unsafe.Pointer(&rect))); ok == 0 {
+		ownWindow = 0
+		return image.Rectangle{}, false
+	}
+	return image.Rect(int(rect.Left), int(rect.Top), int(rect.Right), int(rect.Bottom)), true
+}
@@ -0,0 +1,46 @@
+// finding and following application windows on X11.
+package x11win
+
+import (
+	"errors"
+	"image"
+)
+
+var (
+	ErrNotSupported  = errors.New("following a window is only supported on X11")
+	ErrWindowClosed  = errors.New("the followed window was closed")
+	ErrNoWindowFound = errors.New("no window found")
+)
+
+type Window struct {
+	ID    uint32
+	Title string
+	Class string
+}
+
+func (win Window) String() string {
+	if win.Title != "" {
+		return win.Title
+	}
+	return win.Class
+}
+
+// Conn is a connection to the X server,
+// it's not safe for concurrent use.
+type Conn interface {
+	// Pick waits for the user to click on a window.
+	Pick() (Window, error)
+
+	// Find returns the first window with a title or class that
+	// contains query, ignoring case.
+	Find(query string) (Window, error)
+
+	// Self returns the window of this process.
+	Self() (Window, error)
+
+	// Bounds returns the position and size of the window, relative
+	// to the root window, or ErrWindowClosed if it no longer exists.
+	Bounds(win Window) (image.Rectangle, error)
+
+	Close()
+}
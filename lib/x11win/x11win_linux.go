@@ -0,0 +1,212 @@
+package x11win
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"os"
+	"strings"
+
+	"github.com/jezek/xgb"
+	"github.com/jezek/xgb/xproto"
+)
+
+// from X11/cursorfont.h
+const xcCrosshair = 34
+
+type conn struct {
+	x    *xgb.Conn
+	root xproto.Window
+
+	atomClientList xproto.Atom
+	atomName       xproto.Atom
+	atomUTF8       xproto.Atom
+	atomState      xproto.Atom
+	atomPID        xproto.Atom
+}
+
+func Connect() (Conn, error) {
+	x, err := xgb.NewConn()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrNotSupported, err)
+	}
+	c := &conn{
+		x:    x,
+		root: xproto.Setup(x).DefaultScreen(x).Root,
+	}
+	for name, atom := range map[string]*xproto.Atom{
+		"_NET_CLIENT_LIST": &c.atomClientList,
+		"_NET_WM_NAME":     &c.atomName,
+		"UTF8_STRING":      &c.atomUTF8,
+		"WM_STATE":         &c.atomState,
+		"_NET_WM_PID":      &c.atomPID,
+	} {
+		reply, err := xproto.InternAtom(x, false, uint16(len(name)), name).Reply()
+		if err != nil {
+			x.Close()
+			return nil, err
+		}
+		*atom = reply.Atom
+	}
+	return c, nil
+}
+
+func (c *conn) Close() {
+	c.x.Close()
+}
+
+func (c *conn) Pick() (Window, error) {
+	font, err := xproto.NewFontId(c.x)
+	if err != nil {
+		return Window{}, err
+	}
+	if err := xproto.OpenFontChecked(c.x, font, uint16(len("cursor")), "cursor").Check(); err != nil {
+		return Window{}, err
+	}
+	defer xproto.CloseFont(c.x, font)
+
+	cursor, err := xproto.NewCursorId(c.x)
+	if err != nil {
+		return Window{}, err
+	}
+	err = xproto.CreateGlyphCursorChecked(c.x, cursor, font, font, xcCrosshair, xcCrosshair+1,
+		0, 0, 0, 0xffff, 0xffff, 0xffff).Check()
+	if err != nil {
+		return Window{}, err
+	}
+	defer xproto.FreeCursor(c.x, cursor)
+
+	grab, err := xproto.GrabPointer(c.x, false, c.root, xproto.EventMaskButtonPress,
+		xproto.GrabModeAsync, xproto.GrabModeAsync, c.root, cursor, xproto.TimeCurrentTime).Reply()
+	if err != nil {
+		return Window{}, err
+	}
+	if grab.Status != xproto.GrabStatusSuccess {
+		return Window{}, errors.New("failed to grab the pointer")
+	}
+	defer xproto.UngrabPointer(c.x, xproto.TimeCurrentTime)
+
+	for {
+		ev, xerr := c.x.WaitForEvent()
+		if ev == nil && xerr == nil {
+			return Window{}, errors.New("connection to the X server closed")
+		}
+		if xerr != nil {
+			return Window{}, xerr
+		}
+		press, ok := ev.(xproto.ButtonPressEvent)
+		if !ok {
+			continue
+		}
+		if press.Child == xproto.WindowNone {
+			return Window{}, ErrNoWindowFound
+		}
+		client, _ := c.clientWindow(press.Child)
+		return c.window(client), nil
+	}
+}
+
+// clientWindow finds the application window inside a
+// window manager frame, which is the one with WM_STATE.
+func (c *conn) clientWindow(win xproto.Window) (xproto.Window, bool) {
+	if c.hasProperty(win, c.atomState) {
+		return win, true
+	}
+	tree, err := xproto.QueryTree(c.x, win).Reply()
+	if err != nil {
+		return win, false
+	}
+	for _, child := range tree.Children {
+		if client, ok := c.clientWindow(child); ok {
+			return client, true
+		}
+	}
+	return win, false
+}
+
+func (c *conn) Find(query string) (Window, error) {
+	query = strings.ToLower(query)
+	reply, err := xproto.GetProperty(c.x, false, c.root, c.atomClientList,
+		xproto.AtomWindow, 0, 1<<16).Reply()
+	if err != nil {
+		return Window{}, err
+	}
+	for i := 0; i+4 <= len(reply.Value); i += 4 {
+		id := xgb.Get32(reply.Value[i:])
+		win := c.window(xproto.Window(id))
+		if strings.Contains(strings.ToLower(win.Title), query) ||
+			strings.Contains(strings.ToLower(win.Class), query) {
+			return win, nil
+		}
+	}
+	return Window{}, fmt.Errorf("%w: %v", ErrNoWindowFound, query)
+}
+
+func (c *conn) Self() (Window, error) {
+	reply, err := xproto.GetProperty(c.x, false, c.root, c.atomClientList,
+		xproto.AtomWindow, 0, 1<<16).Reply()
+	if err != nil {
+		return Window{}, err
+	}
+	pid := uint32(os.Getpid())
+	for i := 0; i+4 <= len(reply.Value); i += 4 {
+		id := xproto.Window(xgb.Get32(reply.Value[i:]))
+		prop, err := xproto.GetProperty(c.x, false, id, c.atomPID,
+			xproto.AtomCardinal, 0, 1).Reply()
+		if err == nil && prop.Format == 32 && len(prop.Value) >= 4 && xgb.Get32(prop.Value) == pid {
+			return c.window(id), nil
+		}
+	}
+	return Window{}, fmt.Errorf("%w: pid %v", ErrNoWindowFound, pid)
+}
+
+func (c *conn) Bounds(win Window) (image.Rectangle, error) {
+	id := xproto.Window(win.ID)
+	geom, err := xproto.GetGeometry(c.x, xproto.Drawable(id)).Reply()
+	if err != nil {
+		return image.Rectangle{}, c.checkClosed(err)
+	}
+	pos, err := xproto.TranslateCoordinates(c.x, id, c.root, 0, 0).Reply()
+	if err != nil {
+		return image.Rectangle{}, c.checkClosed(err)
+	}
+	return image.Rect(0, 0, int(geom.Width), int(geom.Height)).
+		Add(image.Pt(int(pos.DstX), int(pos.DstY))), nil
+}
+
+func (c *conn) checkClosed(err error) error {
+	switch err.(type) {
+	case xproto.WindowError, xproto.DrawableError:
+		return ErrWindowClosed
+	}
+	return err
+}
+
+func (c *conn) window(id xproto.Window) Window {
+	win := Window{ID: uint32(id)}
+	win.Title = c.stringProperty(id, c.atomName, c.atomUTF8)
+	if win.Title == "" {
+		win.Title = c.stringProperty(id, xproto.AtomWmName, xproto.AtomString)
+	}
+	// WM_CLASS is the instance and the class, separated by a null
+	class := c.stringProperty(id, xproto.AtomWmClass, xproto.AtomString)
+	if i := strings.IndexByte(class, 0); i >= 0 {
+		class = class[i+1:]
+	}
+	win.Class = strings.TrimRight(class, "\x00")
+	return win
+}
+
+func (c *conn) stringProperty(id xproto.Window, property, typ xproto.Atom) string {
+	reply, err := xproto.GetProperty(c.x, false, id, property, typ, 0, 1024).Reply()
+	if err != nil || reply.Format != 8 {
+		return ""
+	}
+	return string(reply.Value)
+}
+
+func (c *conn) hasProperty(id xproto.Window, property xproto.Atom) bool {
+	reply, err := xproto.GetProperty(c.x, false, id, property,
+		xproto.GetPropertyTypeAny, 0, 0).Reply()
+	return err == nil && reply.Type != xproto.AtomNone
+}
@@ -0,0 +1,7 @@
+//go:build !linux
+
+package x11win
+
+func Connect() (Conn, error) {
+	return nil, ErrNotSupported
+}
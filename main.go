@@ -1,8 +1,10 @@
 package main
 
 import (
+	"fmt"
 	"log"
 	"os"
+	"strings"
 
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/nvlled/screencage/lib"
@@ -10,6 +12,15 @@ import (
 
 func main() {
 	log.SetOutput(os.Stderr)
+
+	if len(os.Args) > 1 && !strings.HasPrefix(os.Args[1], "-") {
+		if err := lib.RunCommand(os.Args[1], os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	ebiten.SetTPS(30)
 	ebiten.SetWindowResizingMode(ebiten.WindowResizingModeEnabled)
 	ebiten.SetWindowDecorated(false)